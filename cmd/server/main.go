@@ -5,6 +5,7 @@ import (
 	"github.com/sendurangr/url-analyzer-api/internal/constants"
 	"github.com/sendurangr/url-analyzer-api/internal/handler"
 	"github.com/sendurangr/url-analyzer-api/internal/middleware"
+	"github.com/sendurangr/url-analyzer-api/internal/netguard"
 	"github.com/sendurangr/url-analyzer-api/internal/routes"
 	"github.com/sendurangr/url-analyzer-api/internal/urlanalyzer"
 	"log/slog"
@@ -25,8 +26,15 @@ func run() error {
 
 	r.GET("/health", handler.HealthCheckHandler)
 
+	// comma separated hosts and CIDRs the analyzer may reach despite being private, e.g. internal staging hosts
+	policy, err := netguard.ParsePolicy(os.Getenv("SSRF_ALLOWLIST"))
+	if err != nil {
+		return err
+	}
+
 	httpClient := &http.Client{
-		Timeout: constants.HttpClientTimeout,
+		Timeout:   constants.HttpClientTimeout,
+		Transport: netguard.NewTransport(policy),
 	}
 
 	apiGroup := r.Group("/api/v1")
@@ -4,6 +4,10 @@ import "time"
 
 const (
 	LinkCheckerConcurrentLimit = 64
+	// GlobalLinkCheckConcurrency bounds the link checks of all concurrent analyses together
+	GlobalLinkCheckConcurrency = 256
+	MaxAnchorTextLength        = 200
+	LinkCheckMaxDrainBytes     = 4 << 10
 	HTML5Version               = "HTML5"
 	LegacyHTMLVersion          = "Older HTML or XHTML"
 )
@@ -12,3 +16,58 @@ const (
 	HttpClientTimeout = 15 * time.Second
 	ContextTimeout    = 20 * time.Second
 )
+
+// Upper bounds for the per-request AnalyzeOptions overrides
+const (
+	MaxFetchTimeout         = 60 * time.Second
+	MaxLinkCheckTimeout     = 120 * time.Second
+	MaxLinkCheckConcurrency = 256
+	MaxRedirectsLimit       = 30
+	MaxBodyBytesLimit       = 50 << 20
+	MaxLinksLimit           = 10_000
+)
+
+const (
+	DefaultMaxRedirects = 10
+	DefaultMaxBodyBytes = 5 << 20
+	DefaultMaxLinks     = 1_000
+)
+
+// StatusClientClosedRequest is the non-standard (nginx) status used when the caller disconnects mid-analysis.
+const StatusClientClosedRequest = 499
+
+const (
+	DefaultRobotsUserAgent = "url-analyzer"
+	RobotsCacheTTL         = time.Hour
+	// RobotsFailureCacheTTL keeps a host that could not be reached from being asked for robots.txt by every link,
+	// without holding on to what may be a passing network failure
+	RobotsFailureCacheTTL = time.Minute
+	RobotsCacheMaxEntries = 10_000
+	// MaxRobotsBytes is the minimum RFC 9309 asks crawlers to parse
+	MaxRobotsBytes = 500 << 10
+	// MaxCrawlDelay caps the Crawl-delay we honor, larger values would exhaust any link-check budget anyway
+	MaxCrawlDelay = 10 * time.Second
+)
+
+// Politeness defaults for link checking
+const (
+	DefaultPerHostConcurrency = 4
+	DefaultRateLimitRetries   = 2
+	MaxRateLimitRetries       = 5
+	RateLimitBaseBackoff      = time.Second
+	// MaxRetryAfter caps how long a single Retry-After may hold up a host
+	MaxRetryAfter = 10 * time.Second
+)
+
+// Link-status cache shared by all analyses. Failures expire sooner, they are more likely to be transient.
+const (
+	LinkCacheSuccessTTL = 10 * time.Minute
+	LinkCacheFailureTTL = time.Minute
+	LinkCacheMaxEntries = 50_000
+)
+
+// LinkProgressBatchSize is how many link checks complete between two progress events
+const LinkProgressBatchSize = 10
+
+// Soft404SniffBytes is how much of a page soft-404 detection reads to find its title and heading
+const Soft404SniffBytes = 64 << 10
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"github.com/sendurangr/url-analyzer-api/internal/urlanalyzer"
+	"strings"
+	"time"
+)
+
+// buildAnalyzeOptions starts from the requested mode preset and applies the individual overrides on top of it.
+func buildAnalyzeOptions(req *model.AnalyzeRequest) (urlanalyzer.AnalyzeOptions, error) {
+	opts, err := urlanalyzer.OptionsForMode(urlanalyzer.Mode(req.Mode))
+	if err != nil {
+		return opts, err
+	}
+
+	if req.SkipLinkCheck != nil {
+		opts.SkipLinkCheck = *req.SkipLinkCheck
+	}
+	if req.MaxLinks != nil {
+		opts.MaxLinks = *req.MaxLinks
+	}
+	if req.LinkSampling != "" {
+		opts.LinkSampling = urlanalyzer.LinkSampling(req.LinkSampling)
+	}
+	if req.SampleSeed != nil {
+		opts.SampleSeed = *req.SampleSeed
+	}
+	if req.LinkCheckConcurrency != nil {
+		opts.LinkCheckConcurrency = *req.LinkCheckConcurrency
+	}
+	if req.MaxRedirects != nil {
+		opts.MaxRedirects = req.MaxRedirects
+	}
+	if req.MaxBodyBytes != nil {
+		opts.MaxBodyBytes = *req.MaxBodyBytes
+	}
+	if req.FailOnBodyTooLarge != nil {
+		opts.FailOnBodyTooLarge = *req.FailOnBodyTooLarge
+	}
+	if req.RespectRobots != nil {
+		opts.RespectRobots = *req.RespectRobots
+	}
+	if req.RobotsUserAgent != "" {
+		opts.RobotsUserAgent = req.RobotsUserAgent
+	}
+	if req.IncludeLinks != nil {
+		opts.IncludeLinkDetails = *req.IncludeLinks
+	}
+	if req.LinkCheckMethod != "" {
+		opts.LinkCheckMethod = urlanalyzer.LinkCheckMethod(req.LinkCheckMethod)
+	}
+	if req.SameSite != "" {
+		opts.SameSitePolicy = urlanalyzer.SameSitePolicy(req.SameSite)
+	}
+	if req.CheckFragments != nil {
+		opts.CheckInternalFragments = *req.CheckFragments
+	}
+	if req.DetectSoft404 != nil {
+		opts.DetectSoft404 = *req.DetectSoft404
+	}
+	if req.BypassLinkCache != nil {
+		opts.BypassLinkCache = *req.BypassLinkCache
+	}
+	if req.PerHostConcurrency != nil {
+		opts.PerHostConcurrency = *req.PerHostConcurrency
+	}
+	if req.HostDelayMs != nil {
+		opts.HostDelay = time.Duration(*req.HostDelayMs) * time.Millisecond
+	}
+	if req.RateLimitRetries != nil {
+		opts.RateLimitRetries = req.RateLimitRetries
+	}
+	if req.FetchTimeoutMs != nil {
+		if *req.FetchTimeoutMs <= 0 {
+			return opts, fmt.Errorf("fetchTimeoutMs must be positive")
+		}
+		opts.FetchTimeout = time.Duration(*req.FetchTimeoutMs) * time.Millisecond
+	}
+	if req.LinkCheckTimeoutMs != nil {
+		if *req.LinkCheckTimeoutMs <= 0 {
+			return opts, fmt.Errorf("linkCheckTimeoutMs must be positive")
+		}
+		opts.LinkCheckTimeout = time.Duration(*req.LinkCheckTimeoutMs) * time.Millisecond
+	}
+
+	for _, name := range splitListParam(req.Extractors) {
+		opts.Extractors = append(opts.Extractors, urlanalyzer.Extractor(name))
+	}
+	opts.InternalHosts = append(opts.InternalHosts, splitListParam(req.InternalHosts)...)
+	if checkResources := splitListParam(req.CheckResources); len(checkResources) > 0 {
+		// the request replaces the mode's choice rather than adding to it
+		opts.CheckResources = nil
+		for _, name := range checkResources {
+			if name == "all" {
+				opts.CheckResources = append(opts.CheckResources, urlanalyzer.AllResourceTypes...)
+			} else {
+				opts.CheckResources = append(opts.CheckResources, urlanalyzer.ResourceType(name))
+			}
+		}
+	}
+
+	return opts, opts.Validate()
+}
+
+// splitListParam accepts both repeated params (?extractors=a&extractors=b) and comma separated lists.
+func splitListParam(values []string) []string {
+	var items []string
+	for _, raw := range values {
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
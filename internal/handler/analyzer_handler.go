@@ -1,13 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
 	"github.com/sendurangr/url-analyzer-api/internal/urlanalyzer"
 	"github.com/sendurangr/url-analyzer-api/internal/utils"
 	"log/slog"
 	"net/http"
 	"net/url"
-	"strings"
 )
 
 type AnalyzerHandler struct {
@@ -18,34 +19,73 @@ func NewAnalyzerHandler(svc urlanalyzer.AnalyzerService) *AnalyzerHandler {
 	return &AnalyzerHandler{Service: svc}
 }
 
+// UrlAnalyzerHandler serves both GET (options as query parameters) and POST (options as a JSON body).
 func (h *AnalyzerHandler) UrlAnalyzerHandler(ctx *gin.Context) {
 
-	rawURL := ctx.Query("url")
+	rawURL, opts, ok := parseAnalyzeRequest(ctx)
+	if !ok {
+		return
+	}
+
+	result, err := h.Service.AnalyzePage(ctx.Request.Context(), rawURL, opts)
+	if errors.Is(err, urlanalyzer.ErrAnalysisCanceled) {
+		// nobody is listening anymore - record it and bail out without treating it as a server failure
+		slog.Info("Analysis canceled by client", "url", rawURL)
+		respondWithAnalyzeError(ctx, err)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to analyze page", "url", rawURL, "error", err)
+		respondWithAnalyzeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// LinkCheckStatsHandler reports the queue depth and utilization of the link checks shared by all analyses.
+func (h *AnalyzerHandler) LinkCheckStatsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.Service.LinkCheckStats())
+}
+
+// parseAnalyzeRequest binds and validates the URL and options of an analyze request. When the request is invalid
+// it responds with a 400 and returns false.
+func parseAnalyzeRequest(ctx *gin.Context) (string, urlanalyzer.AnalyzeOptions, bool) {
+	var req model.AnalyzeRequest
+	if err := bindAnalyzeRequest(ctx, &req); err != nil {
+		slog.Warn("Malformed analyze request", "error", err)
+		utils.RespondWithError(ctx, http.StatusBadRequest, codeMalformedRequest, "Malformed request: "+err.Error())
+		return "", urlanalyzer.AnalyzeOptions{}, false
+	}
+
+	rawURL := req.URL
 	if rawURL == "" {
 		slog.Warn("Missing 'url' query parameter")
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Missing 'url' query parameter")
-		return
+		utils.RespondWithError(ctx, http.StatusBadRequest, codeMissingURL, "Missing 'url' query parameter")
+		return "", urlanalyzer.AnalyzeOptions{}, false
 	}
 
 	// Validate the URL format - and not supporting other schemes like ftp or file
 	parsed, err := url.ParseRequestURI(rawURL)
 	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
 		slog.Warn("Invalid or unsupported URL scheme", "url", rawURL)
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid or unsupported URL. Please use http or https.")
-		return
+		utils.RespondWithError(ctx, http.StatusBadRequest, codeInvalidURL, "Invalid or unsupported URL. Please use http or https.")
+		return "", urlanalyzer.AnalyzeOptions{}, false
 	}
 
-	result, err := h.Service.AnalyzePage(rawURL)
+	opts, err := buildAnalyzeOptions(&req)
 	if err != nil {
-		slog.Error("Failed to analyze page", "url", rawURL, "error", err)
-
-		status := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "HTTP error") {
-			status = http.StatusBadGateway
-		}
-		utils.RespondWithError(ctx, status, err.Error())
-		return
+		slog.Warn("Invalid analyze options", "url", rawURL, "error", err)
+		utils.RespondWithError(ctx, http.StatusBadRequest, codeInvalidOptions, "Invalid options: "+err.Error())
+		return "", urlanalyzer.AnalyzeOptions{}, false
 	}
 
-	ctx.JSON(http.StatusOK, result)
+	return rawURL, opts, true
+}
+
+func bindAnalyzeRequest(ctx *gin.Context, req *model.AnalyzeRequest) error {
+	if ctx.Request.Method == http.MethodPost {
+		return ctx.ShouldBindJSON(req)
+	}
+	return ctx.ShouldBindQuery(req)
 }
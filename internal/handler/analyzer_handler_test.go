@@ -1,32 +1,56 @@
 package handler_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/sendurangr/url-analyzer-api/internal/constants"
 	"github.com/sendurangr/url-analyzer-api/internal/handler"
 	"github.com/sendurangr/url-analyzer-api/internal/model"
-	_ "github.com/sendurangr/url-analyzer-api/internal/urlanalyzer"
+	"github.com/sendurangr/url-analyzer-api/internal/urlanalyzer"
+	"github.com/sendurangr/url-analyzer-api/internal/utils"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 type mockAnalyzerService struct {
 	shouldFail bool
+	err        error
+	gotOpts    urlanalyzer.AnalyzeOptions
+	// progress is reported through opts.Progress before returning
+	progress []model.ProgressEvent
 }
 
-func (m *mockAnalyzerService) AnalyzePage(url string) (*model.AnalyzerResult, error) {
+func (m *mockAnalyzerService) AnalyzePage(ctx context.Context, url string, opts urlanalyzer.AnalyzeOptions) (*model.AnalyzerResult, error) {
+	m.gotOpts = opts
+	for _, event := range m.progress {
+		if opts.Progress != nil {
+			opts.Progress(event)
+		}
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
 	if m.shouldFail {
 		return nil, errors.New("analyze error")
 	}
 	return &model.AnalyzerResult{HTMLVersion: "HTML5"}, nil
 }
 
+func (m *mockAnalyzerService) LinkCheckStats() model.LinkCheckStats {
+	return model.LinkCheckStats{Capacity: 256, InFlight: 64, Queued: 10, ActiveAnalyses: 2, Utilization: 0.25}
+}
+
 func setupRouter(h *handler.AnalyzerHandler) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
 	r.GET("/url-analyzer", h.UrlAnalyzerHandler)
+	r.POST("/url-analyzer", h.UrlAnalyzerHandler)
 	return r
 }
 
@@ -71,3 +95,251 @@ func TestUrlAnalyzerHandler_AnalyzeError(t *testing.T) {
 		t.Errorf("Expected 500 with analyze error, got %d: %s", w.Code, w.Body.String())
 	}
 }
+
+func TestUrlAnalyzerHandler_Canceled(t *testing.T) {
+	h := handler.NewAnalyzerHandler(&mockAnalyzerService{err: urlanalyzer.ErrAnalysisCanceled})
+	r := setupRouter(h)
+
+	req, _ := http.NewRequest(http.MethodGet, "/url-analyzer?url=https://valid.com", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != constants.StatusClientClosedRequest || !strings.Contains(w.Body.String(), "canceled") {
+		t.Errorf("Expected 499 for canceled analysis, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUrlAnalyzerHandler_QueryOptions(t *testing.T) {
+	svc := &mockAnalyzerService{}
+	r := setupRouter(handler.NewAnalyzerHandler(svc))
+
+	req, _ := http.NewRequest(http.MethodGet,
+		"/url-analyzer?url=https://valid.com&mode=metadata&maxLinks=10&fetchTimeoutMs=1500&extractors=title,headings", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !svc.gotOpts.SkipLinkCheck {
+		t.Error("Expected metadata mode to skip link checking")
+	}
+	if svc.gotOpts.MaxLinks != 10 {
+		t.Errorf("Expected maxLinks 10, got %d", svc.gotOpts.MaxLinks)
+	}
+	if svc.gotOpts.FetchTimeout != 1500*time.Millisecond {
+		t.Errorf("Expected fetch timeout 1.5s, got %v", svc.gotOpts.FetchTimeout)
+	}
+	if len(svc.gotOpts.Extractors) != 2 {
+		t.Errorf("Expected 2 extractors, got %v", svc.gotOpts.Extractors)
+	}
+}
+
+func TestUrlAnalyzerHandler_ExplicitZeroOptions(t *testing.T) {
+	svc := &mockAnalyzerService{}
+	r := setupRouter(handler.NewAnalyzerHandler(svc))
+
+	req, _ := http.NewRequest(http.MethodGet, "/url-analyzer?url=https://valid.com&maxRedirects=0&rateLimitRetries=0", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if svc.gotOpts.MaxRedirects == nil || *svc.gotOpts.MaxRedirects != 0 {
+		t.Errorf("Expected an explicit maxRedirects of 0 to be kept, got %v", svc.gotOpts.MaxRedirects)
+	}
+	if svc.gotOpts.RateLimitRetries == nil || *svc.gotOpts.RateLimitRetries != 0 {
+		t.Errorf("Expected an explicit rateLimitRetries of 0 to be kept, got %v", svc.gotOpts.RateLimitRetries)
+	}
+}
+
+func TestUrlAnalyzerHandler_SameSiteOptions(t *testing.T) {
+	svc := &mockAnalyzerService{}
+	r := setupRouter(handler.NewAnalyzerHandler(svc))
+
+	req, _ := http.NewRequest(http.MethodGet,
+		"/url-analyzer?url=https://valid.com&sameSite=host_list&internalHosts=shop.valid.com,cdn.valid.com&internalHosts=blog.valid.com", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if svc.gotOpts.SameSitePolicy != urlanalyzer.SameSiteHostList {
+		t.Errorf("Expected host_list policy, got %q", svc.gotOpts.SameSitePolicy)
+	}
+	if len(svc.gotOpts.InternalHosts) != 3 {
+		t.Errorf("Expected 3 internal hosts, got %v", svc.gotOpts.InternalHosts)
+	}
+}
+
+func TestUrlAnalyzerHandler_JSONBodyOptions(t *testing.T) {
+	svc := &mockAnalyzerService{}
+	r := setupRouter(handler.NewAnalyzerHandler(svc))
+
+	body := `{"url":"https://valid.com","mode":"exhaustive","linkCheckConcurrency":8}`
+	req, _ := http.NewRequest(http.MethodPost, "/url-analyzer", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if svc.gotOpts.LinkCheckConcurrency != 8 {
+		t.Errorf("Expected concurrency 8, got %d", svc.gotOpts.LinkCheckConcurrency)
+	}
+	if svc.gotOpts.LinkCheckTimeout != constants.MaxLinkCheckTimeout {
+		t.Errorf("Expected exhaustive link check timeout, got %v", svc.gotOpts.LinkCheckTimeout)
+	}
+}
+
+func TestUrlAnalyzerHandler_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "unknown mode", query: "&mode=turbo"},
+		{name: "unknown extractor", query: "&extractors=favicon"},
+		{name: "negative maxLinks", query: "&maxLinks=-1"},
+		{name: "timeout too large", query: "&linkCheckTimeoutMs=99999999"},
+		{name: "not a number", query: "&maxLinks=lots"},
+		{name: "host delay too large", query: "&hostDelayMs=60000"},
+		{name: "too many rate limit retries", query: "&rateLimitRetries=100"},
+		{name: "unknown same-site policy", query: "&sameSite=same_planet"},
+		{name: "host list without hosts", query: "&sameSite=host_list"},
+		{name: "hosts without host list", query: "&internalHosts=shop.example.com"},
+		{name: "too many links", query: "&maxLinks=1000000"},
+		{name: "unknown link sampling", query: "&linkSampling=best"},
+		{name: "seed without random sampling", query: "&sampleSeed=7"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouter(handler.NewAnalyzerHandler(&mockAnalyzerService{}))
+
+			req, _ := http.NewRequest(http.MethodGet, "/url-analyzer?url=https://valid.com"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUrlAnalyzerHandler_TypedErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantStatus    int
+		wantCode      string
+		wantUpstream  int
+		wantRetryable bool
+	}{
+		{
+			name:          "upstream 503",
+			err:           &urlanalyzer.AnalyzeError{Code: urlanalyzer.CodeUpstreamStatus, Message: "HTTP error 503", UpstreamStatus: 503, Retryable: true},
+			wantStatus:    http.StatusBadGateway,
+			wantCode:      "upstream_http_status",
+			wantUpstream:  503,
+			wantRetryable: true,
+		},
+		{
+			name:          "timeout",
+			err:           &urlanalyzer.AnalyzeError{Code: urlanalyzer.CodeTimeout, Message: "too slow", Retryable: true},
+			wantStatus:    http.StatusGatewayTimeout,
+			wantCode:      "timeout",
+			wantRetryable: true,
+		},
+		{
+			name:       "non html",
+			err:        &urlanalyzer.AnalyzeError{Code: urlanalyzer.CodeNonHTMLContent, Message: "not html"},
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantCode:   "non_html_content",
+		},
+		{
+			name:       "wrapped blocked target",
+			err:        fmt.Errorf("wrapped: %w", &urlanalyzer.AnalyzeError{Code: urlanalyzer.CodeBlockedTarget, Message: "blocked"}),
+			wantStatus: http.StatusForbidden,
+			wantCode:   "blocked_target",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouter(handler.NewAnalyzerHandler(&mockAnalyzerService{err: tc.err}))
+
+			req, _ := http.NewRequest(http.MethodGet, "/url-analyzer?url=https://valid.com", nil)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+
+			var body utils.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode error body: %v", err)
+			}
+			if body.Code != tc.wantCode || body.UpstreamStatus != tc.wantUpstream || body.Retryable != tc.wantRetryable {
+				t.Errorf("Unexpected error body %+v", body)
+			}
+		})
+	}
+}
+
+func TestLinkCheckStatsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/url-analyzer/stats", handler.NewAnalyzerHandler(&mockAnalyzerService{}).LinkCheckStatsHandler)
+
+	req, _ := http.NewRequest(http.MethodGet, "/url-analyzer/stats", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var stats model.LinkCheckStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.Capacity != 256 || stats.Queued != 10 || stats.Utilization != 0.25 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestUrlAnalyzerHandler_RedirectErrorCarriesChain(t *testing.T) {
+	redirects := &model.Redirects{
+		Chain: []model.RedirectHop{{URL: "https://valid.com", StatusCode: http.StatusFound, Location: "https://valid.com"}},
+		Count: 1,
+		Loop:  true,
+	}
+	svc := &mockAnalyzerService{err: &urlanalyzer.AnalyzeError{Code: urlanalyzer.CodeRedirectLoop, Message: "loop", Redirects: redirects}}
+	r := setupRouter(handler.NewAnalyzerHandler(svc))
+
+	req, _ := http.NewRequest(http.MethodGet, "/url-analyzer?url=https://valid.com", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	var body utils.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode error body: %v", err)
+	}
+	if body.Code != "redirect_loop" || body.Redirects == nil || !body.Redirects.Loop || len(body.Redirects.Chain) != 1 {
+		t.Errorf("Expected the redirect chain in the error body, got %+v", body)
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"github.com/sendurangr/url-analyzer-api/internal/urlanalyzer"
+	"log/slog"
+	"net/http"
+)
+
+// Server-Sent Event names used by UrlAnalyzerStreamHandler
+const (
+	eventProgress = "progress"
+	eventResult   = "result"
+	eventError    = "error"
+)
+
+// UrlAnalyzerStreamHandler analyzes a page like UrlAnalyzerHandler, but streams the analysis as Server-Sent Events:
+// a "progress" event per completed stage, then a single "result" event with the AnalyzerResult or an "error" event
+// with the error body. Invalid requests are still rejected with a plain JSON 400 before the stream starts.
+func (h *AnalyzerHandler) UrlAnalyzerStreamHandler(ctx *gin.Context) {
+
+	rawURL, opts, ok := parseAnalyzeRequest(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	// keep reverse proxies like nginx from buffering the stream
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	send := func(event string, data any) {
+		ctx.SSEvent(event, data)
+		ctx.Writer.Flush()
+	}
+
+	opts.Progress = func(event model.ProgressEvent) {
+		send(eventProgress, event)
+	}
+
+	result, err := h.Service.AnalyzePage(ctx.Request.Context(), rawURL, opts)
+	if errors.Is(err, urlanalyzer.ErrAnalysisCanceled) {
+		// the client closed the stream, there is nobody to send the error to
+		slog.Info("Analysis canceled by client", "url", rawURL)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to analyze page", "url", rawURL, "error", err)
+		_, body := analyzeErrorResponse(err)
+		send(eventError, body)
+		return
+	}
+
+	send(eventResult, result)
+}
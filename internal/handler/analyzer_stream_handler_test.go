@@ -0,0 +1,127 @@
+package handler_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/sendurangr/url-analyzer-api/internal/handler"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"github.com/sendurangr/url-analyzer-api/internal/urlanalyzer"
+	"github.com/sendurangr/url-analyzer-api/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+func setupStreamRouter(h *handler.AnalyzerHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/url-analyzer/stream", h.UrlAnalyzerStreamHandler)
+	return r
+}
+
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current.name != "" {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		case strings.HasPrefix(line, "event:"):
+			current.name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			current.data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	return events
+}
+
+func TestUrlAnalyzerStreamHandler_Success(t *testing.T) {
+	svc := &mockAnalyzerService{progress: []model.ProgressEvent{
+		{Stage: urlanalyzer.StageFetched, Fetch: &model.FetchProgress{FinalURL: "https://valid.com/", StatusCode: 200}},
+		{Stage: urlanalyzer.StageLinkCheck, Links: &model.LinkProgress{Total: 2, Done: 2, Accessible: 1, Inaccessible: 1}},
+	}}
+	r := setupStreamRouter(handler.NewAnalyzerHandler(svc))
+
+	req, _ := http.NewRequest(http.MethodGet, "/url-analyzer/stream?url=https://valid.com", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Expected an event stream, got Content-Type %q", ct)
+	}
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 3 {
+		t.Fatalf("Expected 2 progress events and a result, got %+v", events)
+	}
+
+	var progress model.ProgressEvent
+	if events[1].name != "progress" || json.Unmarshal([]byte(events[1].data), &progress) != nil {
+		t.Fatalf("Expected a progress event, got %+v", events[1])
+	}
+	if progress.Stage != urlanalyzer.StageLinkCheck || progress.Links == nil || progress.Links.Inaccessible != 1 {
+		t.Errorf("Unexpected progress event %+v", progress)
+	}
+
+	var result model.AnalyzerResult
+	if events[2].name != "result" || json.Unmarshal([]byte(events[2].data), &result) != nil {
+		t.Fatalf("Expected a result event, got %+v", events[2])
+	}
+	if result.HTMLVersion != "HTML5" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestUrlAnalyzerStreamHandler_Error(t *testing.T) {
+	svc := &mockAnalyzerService{err: &urlanalyzer.AnalyzeError{Code: urlanalyzer.CodeTimeout, Message: "timed out", Retryable: true}}
+	r := setupStreamRouter(handler.NewAnalyzerHandler(svc))
+
+	req, _ := http.NewRequest(http.MethodGet, "/url-analyzer/stream?url=https://valid.com", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 1 || events[0].name != "error" {
+		t.Fatalf("Expected a single error event, got %+v", events)
+	}
+
+	var body utils.ErrorResponse
+	if err := json.Unmarshal([]byte(events[0].data), &body); err != nil {
+		t.Fatalf("Failed to decode error event: %v", err)
+	}
+	if body.Code != string(urlanalyzer.CodeTimeout) || !body.Retryable {
+		t.Errorf("Unexpected error body %+v", body)
+	}
+}
+
+func TestUrlAnalyzerStreamHandler_InvalidRequest(t *testing.T) {
+	r := setupStreamRouter(handler.NewAnalyzerHandler(&mockAnalyzerService{}))
+
+	req, _ := http.NewRequest(http.MethodGet, "/url-analyzer/stream?url=ftp://valid.com", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a plain 400 before the stream starts, got %d: %s", w.Code, w.Body.String())
+	}
+}
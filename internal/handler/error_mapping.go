@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sendurangr/url-analyzer-api/internal/constants"
+	"github.com/sendurangr/url-analyzer-api/internal/urlanalyzer"
+	"github.com/sendurangr/url-analyzer-api/internal/utils"
+	"net/http"
+)
+
+// Error codes for requests rejected before the analyzer is called
+const (
+	codeMalformedRequest = "malformed_request"
+	codeMissingURL       = "missing_url"
+	codeInvalidURL       = "invalid_url"
+	codeInvalidOptions   = "invalid_options"
+)
+
+var statusByErrorCode = map[urlanalyzer.ErrorCode]int{
+	urlanalyzer.CodeUpstreamStatus:   http.StatusBadGateway,
+	urlanalyzer.CodeDNSFailure:       http.StatusBadGateway,
+	urlanalyzer.CodeTLSFailure:       http.StatusBadGateway,
+	urlanalyzer.CodeConnectionFailed: http.StatusBadGateway,
+	urlanalyzer.CodeRedirectLoop:     http.StatusBadGateway,
+	urlanalyzer.CodeTooManyRedirects: http.StatusBadGateway,
+	urlanalyzer.CodeTimeout:          http.StatusGatewayTimeout,
+	urlanalyzer.CodeNonHTMLContent:   http.StatusUnsupportedMediaType,
+	urlanalyzer.CodeContentMismatch:  http.StatusUnprocessableEntity,
+	urlanalyzer.CodeBodyTooLarge:     http.StatusUnprocessableEntity,
+	urlanalyzer.CodeParseFailure:     http.StatusUnprocessableEntity,
+	urlanalyzer.CodeBlockedTarget:    http.StatusForbidden,
+	urlanalyzer.CodeRobotsDisallowed: http.StatusForbidden,
+	urlanalyzer.CodeCanceled:         constants.StatusClientClosedRequest,
+	urlanalyzer.CodeInternal:         http.StatusInternalServerError,
+}
+
+// respondWithAnalyzeError maps an error returned by the analyzer to a status code and a structured error body.
+func respondWithAnalyzeError(ctx *gin.Context, err error) {
+	status, body := analyzeErrorResponse(err)
+	utils.RespondWithErrorResponse(ctx, status, body)
+}
+
+// analyzeErrorResponse maps an error returned by the analyzer to a status code and a structured error body.
+// Errors that are not *urlanalyzer.AnalyzeError are treated as internal errors.
+func analyzeErrorResponse(err error) (int, utils.ErrorResponse) {
+	var analyzeErr *urlanalyzer.AnalyzeError
+	if !errors.As(err, &analyzeErr) {
+		return http.StatusInternalServerError, utils.ErrorResponse{Message: err.Error(), Code: string(urlanalyzer.CodeInternal)}
+	}
+
+	status, ok := statusByErrorCode[analyzeErr.Code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	return status, utils.ErrorResponse{
+		Message:        analyzeErr.Error(),
+		Code:           string(analyzeErr.Code),
+		UpstreamStatus: analyzeErr.UpstreamStatus,
+		Retryable:      analyzeErr.Retryable,
+		Redirects:      analyzeErr.Redirects,
+	}
+}
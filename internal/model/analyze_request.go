@@ -0,0 +1,33 @@
+package model
+
+// AnalyzeRequest carries the URL to analyze and the optional per-request overrides.
+// It binds from the query string on GET and from a JSON body on POST.
+// Pointer fields distinguish "not provided" from an explicit zero.
+type AnalyzeRequest struct {
+	URL                  string   `form:"url" json:"url"`
+	Mode                 string   `form:"mode" json:"mode"`
+	SkipLinkCheck        *bool    `form:"skipLinkCheck" json:"skipLinkCheck"`
+	MaxLinks             *int     `form:"maxLinks" json:"maxLinks"`
+	LinkSampling         string   `form:"linkSampling" json:"linkSampling"`
+	SampleSeed           *int64   `form:"sampleSeed" json:"sampleSeed"`
+	LinkCheckConcurrency *int     `form:"linkCheckConcurrency" json:"linkCheckConcurrency"`
+	FetchTimeoutMs       *int     `form:"fetchTimeoutMs" json:"fetchTimeoutMs"`
+	LinkCheckTimeoutMs   *int     `form:"linkCheckTimeoutMs" json:"linkCheckTimeoutMs"`
+	Extractors           []string `form:"extractors" json:"extractors"`
+	MaxRedirects         *int     `form:"maxRedirects" json:"maxRedirects"`
+	MaxBodyBytes         *int64   `form:"maxBodyBytes" json:"maxBodyBytes"`
+	FailOnBodyTooLarge   *bool    `form:"failOnBodyTooLarge" json:"failOnBodyTooLarge"`
+	RespectRobots        *bool    `form:"respectRobots" json:"respectRobots"`
+	RobotsUserAgent      string   `form:"robotsUserAgent" json:"robotsUserAgent"`
+	IncludeLinks         *bool    `form:"includeLinks" json:"includeLinks"`
+	LinkCheckMethod      string   `form:"linkCheckMethod" json:"linkCheckMethod"`
+	PerHostConcurrency   *int     `form:"perHostConcurrency" json:"perHostConcurrency"`
+	HostDelayMs          *int     `form:"hostDelayMs" json:"hostDelayMs"`
+	RateLimitRetries     *int     `form:"rateLimitRetries" json:"rateLimitRetries"`
+	SameSite             string   `form:"sameSite" json:"sameSite"`
+	InternalHosts        []string `form:"internalHosts" json:"internalHosts"`
+	CheckFragments       *bool    `form:"checkFragments" json:"checkFragments"`
+	CheckResources       []string `form:"checkResources" json:"checkResources"`
+	BypassLinkCache      *bool    `form:"bypassLinkCache" json:"bypassLinkCache"`
+	DetectSoft404        *bool    `form:"detectSoft404" json:"detectSoft404"`
+}
@@ -0,0 +1,13 @@
+package model
+
+// LinkCheckStats is a snapshot of the link checks shared by all in-flight analyses.
+type LinkCheckStats struct {
+	// Capacity is the process-wide ceiling on link checks in flight
+	Capacity int `json:"capacity"`
+	InFlight int `json:"inFlight"`
+	// Queued counts link checks waiting for a slot, either under the ceiling or under their analysis' own limit
+	Queued         int `json:"queued"`
+	ActiveAnalyses int `json:"activeAnalyses"`
+	// Utilization is InFlight as a share of Capacity, between 0 and 1
+	Utilization float64 `json:"utilization"`
+}
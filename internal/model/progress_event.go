@@ -0,0 +1,38 @@
+package model
+
+// ProgressEvent is reported while a page is being analyzed. Which of the optional parts is set depends on Stage.
+type ProgressEvent struct {
+	// Stage is fetched, parsed, metadata or link_check
+	Stage string `json:"stage"`
+	// Fetch is set for the fetched stage
+	Fetch *FetchProgress `json:"fetch,omitempty"`
+	// Content is set for the parsed stage
+	Content *Content `json:"content,omitempty"`
+	// Metadata is set for the metadata stage
+	Metadata *MetadataProgress `json:"metadata,omitempty"`
+	// Links is set for the link_check stage
+	Links *LinkProgress `json:"links,omitempty"`
+}
+
+type FetchProgress struct {
+	FinalURL   string `json:"finalUrl"`
+	StatusCode int    `json:"statusCode"`
+	Redirects  int    `json:"redirects"`
+}
+
+type MetadataProgress struct {
+	HTMLVersion string   `json:"htmlVersion"`
+	PageTitle   string   `json:"pageTitle"`
+	Headings    Headings `json:"headings"`
+	// LinksFound counts the unique links that are about to be checked or classified
+	LinksFound int `json:"linksFound"`
+}
+
+// LinkProgress holds the running counts of the link-check stage.
+type LinkProgress struct {
+	Total        int `json:"total"`
+	Done         int `json:"done"`
+	Accessible   int `json:"accessible"`
+	Inaccessible int `json:"inaccessible"`
+	Unchecked    int `json:"unchecked"`
+}
@@ -1,16 +1,221 @@
 package model
 
 type AnalyzerResult struct {
-	HTMLVersion               string   `json:"htmlVersion"`
-	PageTitle                 string   `json:"pageTitle"`
-	Headings                  Headings `json:"headings"`
-	InternalLinks             int      `json:"internalLinks"`
-	ExternalLinks             int      `json:"externalLinks"`
-	InaccessibleInternalLinks int      `json:"inaccessibleInternalLinks"`
-	InaccessibleExternalLinks int      `json:"inaccessibleExternalLinks"`
-	LoginFormDetected         bool     `json:"loginFormDetected"`
-	TimeTakenToAnalyze        float32  `json:"timeTakenToAnalyze"`
-	URL                       string   `json:"url"`
+	HTMLVersion string   `json:"htmlVersion"`
+	PageTitle   string   `json:"pageTitle"`
+	Headings    Headings `json:"headings"`
+	// TotalLinks counts every link in the document, UniqueLinks each distinct URL once. The other link counters
+	// are per unique URL.
+	TotalLinks                int `json:"totalLinks"`
+	UniqueLinks               int `json:"uniqueLinks"`
+	InternalLinks             int `json:"internalLinks"`
+	ExternalLinks             int `json:"externalLinks"`
+	InaccessibleInternalLinks int `json:"inaccessibleInternalLinks"`
+	InaccessibleExternalLinks int `json:"inaccessibleExternalLinks"`
+	LinksChecked              int `json:"linksChecked"`
+	RobotsSkippedLinks        int `json:"robotsSkippedLinks"`
+	RateLimitedLinks          int `json:"rateLimitedLinks"`
+	// UncheckedLinks have no verdict, UncheckedDueToTimeout and UncheckedDueToCap count the ones that would have
+	// been checked if the link-check budget or maxLinks had allowed it
+	UncheckedLinks        int `json:"uncheckedLinks"`
+	UncheckedDueToTimeout int `json:"uncheckedDueToTimeout"`
+	UncheckedDueToCap     int `json:"uncheckedDueToCap"`
+	// Partial is set when some links, resources or fragments that were meant to be checked have no verdict
+	// (timeout, cap or rate limiting), so the inaccessible and broken counts are a lower bound
+	Partial bool `json:"partial"`
+	// LinkSample tells which share of the links was checked, it is empty when link checking was skipped
+	LinkSample LinkSample `json:"linkSample"`
+	// LinkCacheHits counts links whose outcome was reused from an earlier analysis
+	LinkCacheHits int `json:"linkCacheHits"`
+	// Soft404Links counts accessible links that serve a "not found" page, only set with soft-404 detection
+	Soft404Links       int     `json:"soft404Links"`
+	LoginFormDetected  bool    `json:"loginFormDetected"`
+	TimeTakenToAnalyze float32 `json:"timeTakenToAnalyze"`
+	URL                string  `json:"url"`
+	// Soft404 is set when the analyzed page itself is a "not found" page served with a success status, see
+	// Soft404Reason (not_found_text or matches_missing_page). Only set with soft-404 detection.
+	Soft404       bool   `json:"soft404"`
+	Soft404Reason string `json:"soft404Reason,omitempty"`
+	// BaseURL is what relative links were resolved against: the <base href> when the page has one, otherwise the
+	// final page URL. BaseHref is the raw <base href> value.
+	BaseURL   string    `json:"baseUrl"`
+	BaseHref  string    `json:"baseHref,omitempty"`
+	Redirects Redirects `json:"redirects"`
+	Encoding  Encoding  `json:"encoding"`
+	Content   Content   `json:"content"`
+	// SameSite is the policy that decided which links are internal
+	SameSite SameSite `json:"sameSite"`
+	// NonHTTPLinks counts mailto:, tel:, javascript: and similar links by scheme. They are never checked and are
+	// not part of the internal/external counters.
+	NonHTTPLinks NonHTTPLinks `json:"nonHttpLinks"`
+	// JavaScriptLinks lists the hrefs of javascript: links, an accessibility and security concern
+	JavaScriptLinks []string       `json:"javascriptLinks,omitempty"`
+	FragmentLinks   FragmentLinks  `json:"fragmentLinks"`
+	LinkAttributes  LinkAttributes `json:"linkAttributes"`
+	// Resources is the inventory of images, scripts, stylesheets and other resources the page references
+	Resources Resources `json:"resources"`
+	// RepeatedLinks lists the URLs that appear more than once in the document
+	RepeatedLinks []LinkOccurrence `json:"repeatedLinks,omitempty"`
+	// Links is only populated when the per-link report was requested
+	Links []LinkReport `json:"links,omitempty"`
+}
+
+// LinkSample compares the HTTP links discovered on the page with the ones sampled for checking.
+type LinkSample struct {
+	// Strategy is first, stratified or random
+	Strategy string `json:"strategy"`
+	// Seed reproduces a random sample when passed back as sampleSeed
+	Seed       int64 `json:"seed,omitempty"`
+	MaxLinks   int   `json:"maxLinks"`
+	Discovered int   `json:"discovered"`
+	Sampled    int   `json:"sampled"`
+}
+
+// LinkAttributes audits the rel and target attributes of the page's HTTP links, counted per anchor.
+type LinkAttributes struct {
+	NofollowInternal int `json:"nofollowInternal"`
+	NofollowExternal int `json:"nofollowExternal"`
+	Sponsored        int `json:"sponsored"`
+	UGC              int `json:"ugc"`
+	TargetBlank      int `json:"targetBlank"`
+	// BlankWithoutNoopener counts external links opening in a new tab without rel="noopener" or "noreferrer"
+	BlankWithoutNoopener int `json:"blankWithoutNoopener"`
+}
+
+type Resources struct {
+	Total int `json:"total"`
+	// ByType is keyed by image, script, stylesheet, link, iframe, area, source, media or form
+	ByType map[string]ResourceGroup `json:"byType"`
+	Broken []BrokenResource         `json:"broken,omitempty"`
+}
+
+// ResourceGroup counts the resources of one type. Checked, Inaccessible and Unchecked are per unique URL and stay
+// zero unless reachability checks were requested for the type.
+type ResourceGroup struct {
+	Count        int `json:"count"`
+	Unique       int `json:"unique"`
+	Checked      int `json:"checked"`
+	Inaccessible int `json:"inaccessible"`
+	// Unchecked counts the resources that were meant to be checked but have no verdict (timeout, cap or rate limiting)
+	Unchecked int `json:"unchecked"`
+}
+
+type BrokenResource struct {
+	Type          string `json:"type"`
+	URL           string `json:"url"`
+	StatusCode    int    `json:"statusCode,omitempty"`
+	ErrorCategory string `json:"errorCategory,omitempty"`
+}
+
+// FragmentLinks reports whether links to #fragments point at an existing id or anchor name.
+type FragmentLinks struct {
+	Checked int `json:"checked"`
+	// Unchecked counts fragments into other pages that were not verified, UncheckedDueToTimeout the ones among them
+	// whose page could not be fetched within the link-check budget
+	Unchecked             int              `json:"unchecked"`
+	UncheckedDueToTimeout int              `json:"uncheckedDueToTimeout"`
+	Broken                []BrokenFragment `json:"broken,omitempty"`
+}
+
+type BrokenFragment struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+	// URL is the page the fragment points into
+	URL      string `json:"url"`
+	Fragment string `json:"fragment"`
+}
+
+type SameSite struct {
+	// Policy is exact_host, registrable_domain or host_list
+	Policy string `json:"policy"`
+	// Domain is the registrable domain links were matched against
+	Domain        string   `json:"domain,omitempty"`
+	InternalHosts []string `json:"internalHosts,omitempty"`
+}
+
+type NonHTTPLinks struct {
+	Mailto     int `json:"mailto"`
+	Tel        int `json:"tel"`
+	JavaScript int `json:"javascript"`
+	Data       int `json:"data"`
+	FTP        int `json:"ftp"`
+	Other      int `json:"other"`
+}
+
+// LinkOccurrence is how often a URL appears in the document.
+type LinkOccurrence struct {
+	URL   string `json:"url"`
+	Count int    `json:"count"`
+}
+
+// LinkReport is the outcome of checking a single link.
+type LinkReport struct {
+	URL  string `json:"url"`
+	Href string `json:"href"`
+	Text string `json:"text"`
+	// Internal is true for links to the host of the analyzed page
+	Internal bool `json:"internal"`
+	// Occurrences is how often the URL appears in the document, Href, Text, Rel and Target are taken from the first one
+	Occurrences int    `json:"occurrences"`
+	Rel         string `json:"rel,omitempty"`
+	Target      string `json:"target,omitempty"`
+	// Status is one of accessible, inaccessible or unchecked, or rate_limited, skipped_robots or non_http
+	// for links that were deliberately not given a verdict
+	Status     string `json:"status"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	// Method is the HTTP method whose response decided Status
+	Method string `json:"method,omitempty"`
+	// ErrorCategory explains an inaccessible link: dns, tls, timeout, refused, connection, blocked, redirects,
+	// invalid_url, 4xx or 5xx
+	ErrorCategory string `json:"errorCategory,omitempty"`
+	LatencyMs     int64  `json:"latencyMs"`
+	// FinalURL is where the link ended up after following redirects
+	FinalURL string `json:"finalUrl,omitempty"`
+	// Cached is true when the outcome was reused from an earlier analysis
+	Cached bool `json:"cached,omitempty"`
+	// UncheckedReason explains an unchecked link: skip_link_check, max_links or timeout
+	UncheckedReason string `json:"uncheckedReason,omitempty"`
+	// Soft404 is true when the link is accessible but serves a "not found" page
+	Soft404 bool `json:"soft404,omitempty"`
+}
+
+// Content describes the body of the analyzed page as served.
+type Content struct {
+	MediaType string `json:"mediaType"`
+	// Sniffed is true when MediaType was detected from the body because the Content-Type header was missing
+	Sniffed bool `json:"sniffed"`
+	// ContentLength is the declared Content-Length, -1 when the server did not send one
+	ContentLength int64 `json:"contentLength"`
+	BytesRead     int64 `json:"bytesRead"`
+	// Truncated is true when the body exceeded the size limit and only the first BytesRead bytes were analyzed
+	Truncated bool `json:"truncated"`
+}
+
+// Encoding reports the character sets declared by the page and the one actually used to decode it.
+type Encoding struct {
+	Detected           string `json:"detected"`
+	ContentTypeCharset string `json:"contentTypeCharset,omitempty"`
+	MetaCharset        string `json:"metaCharset,omitempty"`
+	BOM                string `json:"bom,omitempty"`
+	Conflict           bool   `json:"conflict"`
+}
+
+// Redirects describes how the analyzed URL was reached. Chain includes the final, non-redirect response.
+// Loop and TooManyRedirects can only be set on the redirects of a redirect_loop or too_many_redirects error.
+type Redirects struct {
+	Chain            []RedirectHop `json:"chain"`
+	Count            int           `json:"count"`
+	FinalURL         string        `json:"finalUrl"`
+	Loop             bool          `json:"loop"`
+	TooManyRedirects bool          `json:"tooManyRedirects"`
+	HTTPSDowngrade   bool          `json:"httpsDowngrade"`
+}
+
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	Location   string `json:"location,omitempty"`
+	LatencyMs  int64  `json:"latencyMs"`
 }
 
 type Headings struct {
@@ -0,0 +1,141 @@
+package netguard
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// blockedPrefixes are ranges that are not covered by the netip.Addr helpers but must never be reachable
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),         // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),     // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),      // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),     // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),       // reserved, includes broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),      // NAT64, can map onto any of the above
+	netip.MustParsePrefix("fd00:ec2::254/128"), // AWS metadata over IPv6
+}
+
+// Policy decides which destinations outbound requests may connect to.
+// Everything that is not a public unicast address is blocked unless it is allowlisted.
+type Policy struct {
+	// AllowedHosts are host names exempt from the IP checks, e.g. internal staging hosts.
+	// A leading "*." matches any subdomain.
+	AllowedHosts []string
+	// AllowedNetworks are ranges exempt from the IP checks.
+	AllowedNetworks []netip.Prefix
+}
+
+// BlockedError is returned when a connection is refused by the Policy.
+type BlockedError struct {
+	Host   string
+	IP     netip.Addr
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	if e.Host != "" && e.Host != e.IP.String() {
+		return fmt.Sprintf("connection to %s (%s) blocked: %s", e.Host, e.IP, e.Reason)
+	}
+	return fmt.Sprintf("connection to %s blocked: %s", e.IP, e.Reason)
+}
+
+// ParsePolicy builds a Policy from a comma separated allowlist of host names and CIDR ranges,
+// e.g. "staging.internal,*.dev.example.com,10.20.0.0/16". An empty string yields the default, deny-private policy.
+func ParsePolicy(allowlist string) (Policy, error) {
+	var p Policy
+
+	for _, entry := range strings.Split(allowlist, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return Policy{}, fmt.Errorf("invalid allowlist network %q: %w", entry, err)
+			}
+			p.AllowedNetworks = append(p.AllowedNetworks, prefix.Masked())
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			p.AllowedNetworks = append(p.AllowedNetworks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		p.AllowedHosts = append(p.AllowedHosts, strings.ToLower(entry))
+	}
+
+	return p, nil
+}
+
+// hostAllowed reports whether host is on the host name allowlist.
+func (p Policy) hostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range p.AllowedHosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckIP returns a *BlockedError when ip may not be connected to.
+func (p Policy) CheckIP(host string, ip netip.Addr) error {
+	ip = ip.Unmap()
+
+	for _, allowed := range p.AllowedNetworks {
+		if allowed.Contains(ip) {
+			return nil
+		}
+	}
+
+	if reason := blockedReason(ip); reason != "" {
+		return &BlockedError{Host: host, IP: ip, Reason: reason}
+	}
+	return nil
+}
+
+func blockedReason(ip netip.Addr) string {
+	switch {
+	case !ip.IsValid():
+		return "invalid address"
+	case ip.IsLoopback():
+		return "loopback address"
+	case ip.IsPrivate():
+		return "private address"
+	case ip.IsLinkLocalUnicast():
+		// also covers the 169.254.169.254 cloud metadata endpoint
+		return "link-local address"
+	case ip.IsUnspecified():
+		return "unspecified address"
+	case ip.IsMulticast(), ip.IsLinkLocalMulticast(), ip.IsInterfaceLocalMulticast():
+		return "multicast address"
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return "reserved address"
+		}
+	}
+	return ""
+}
+
+// addrFromDialAddress extracts the IP from the "ip:port" address handed to a dialer's Control function.
+func addrFromDialAddress(address string) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return netip.ParseAddr(host)
+}
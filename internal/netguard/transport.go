@@ -0,0 +1,53 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// NewTransport returns an http.Transport whose dialer enforces policy on the resolved IP right before connecting.
+// Checking at connect time rather than on the URL defeats DNS rebinding, and because every redirect hop and
+// every link check dials through the same transport, they are all subject to the same policy.
+func NewTransport(policy Policy) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	guardedDialer := *dialer
+	guardedDialer.Control = func(network, address string, _ syscall.RawConn) error {
+		ip, err := addrFromDialAddress(address)
+		if err != nil {
+			return err
+		}
+		return policy.CheckIP("", ip)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would make the dialer see the proxy's address instead of the target's
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if policy.hostAllowed(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		conn, err := guardedDialer.DialContext(ctx, network, addr)
+
+		// Control only sees the IP, fill in the host name for a more useful error message
+		var blocked *BlockedError
+		if errors.As(err, &blocked) {
+			blocked.Host = host
+		}
+		return conn, err
+	}
+
+	return transport
+}
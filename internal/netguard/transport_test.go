@@ -0,0 +1,89 @@
+package netguard
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestPolicy_CheckIP(t *testing.T) {
+	tests := []struct {
+		ip          string
+		wantBlocked bool
+	}{
+		{ip: "93.184.216.34", wantBlocked: false},
+		{ip: "2606:2800:220:1::", wantBlocked: false},
+		{ip: "127.0.0.1", wantBlocked: true},
+		{ip: "::1", wantBlocked: true},
+		{ip: "10.1.2.3", wantBlocked: true},
+		{ip: "172.16.0.1", wantBlocked: true},
+		{ip: "192.168.1.1", wantBlocked: true},
+		{ip: "169.254.169.254", wantBlocked: true},
+		{ip: "fd00:ec2::254", wantBlocked: true},
+		{ip: "100.64.0.1", wantBlocked: true},
+		{ip: "0.0.0.0", wantBlocked: true},
+		{ip: "::ffff:127.0.0.1", wantBlocked: true},
+	}
+
+	var policy Policy
+	for _, tc := range tests {
+		t.Run(tc.ip, func(t *testing.T) {
+			err := policy.CheckIP("", netip.MustParseAddr(tc.ip))
+			if (err != nil) != tc.wantBlocked {
+				t.Errorf("expected blocked=%v, got %v", tc.wantBlocked, err)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("staging.internal, *.dev.example.com,10.20.0.0/16,192.168.1.7")
+	if err != nil {
+		t.Fatalf("ParsePolicy failed: %v", err)
+	}
+
+	if !policy.hostAllowed("staging.internal") || !policy.hostAllowed("api.dev.example.com") || policy.hostAllowed("example.com") {
+		t.Errorf("unexpected host allowlist %v", policy.AllowedHosts)
+	}
+	if policy.CheckIP("", netip.MustParseAddr("10.20.3.4")) != nil || policy.CheckIP("", netip.MustParseAddr("192.168.1.7")) != nil {
+		t.Errorf("expected allowlisted networks to pass, got %v", policy.AllowedNetworks)
+	}
+	if policy.CheckIP("", netip.MustParseAddr("10.21.0.1")) == nil {
+		t.Error("expected addresses outside the allowlist to stay blocked")
+	}
+
+	if _, err := ParsePolicy("10.0.0.0/99"); err == nil {
+		t.Error("expected an invalid CIDR to be rejected")
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	t.Run("blocks loopback by default", func(t *testing.T) {
+		client := &http.Client{Transport: NewTransport(Policy{})}
+
+		_, err := client.Get(ts.URL)
+
+		var blocked *BlockedError
+		if !errors.As(err, &blocked) {
+			t.Fatalf("expected *BlockedError, got %v", err)
+		}
+	})
+
+	t.Run("allowlisted network passes", func(t *testing.T) {
+		policy, _ := ParsePolicy("127.0.0.0/8")
+		client := &http.Client{Transport: NewTransport(policy)}
+
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("expected allowlisted request to succeed, got %v", err)
+		}
+		_ = resp.Body.Close()
+	})
+}
@@ -8,4 +8,8 @@ import (
 
 func SetupRouters(router *gin.RouterGroup, analyzerHandler *handler.AnalyzerHandler) {
 	router.GET("/url-analyzer", analyzerHandler.UrlAnalyzerHandler)
+	router.POST("/url-analyzer", analyzerHandler.UrlAnalyzerHandler)
+	router.GET("/url-analyzer/stream", analyzerHandler.UrlAnalyzerStreamHandler)
+	router.POST("/url-analyzer/stream", analyzerHandler.UrlAnalyzerStreamHandler)
+	router.GET("/url-analyzer/stats", analyzerHandler.LinkCheckStatsHandler)
 }
@@ -0,0 +1,60 @@
+package urlanalyzer
+
+import (
+	"fmt"
+	"io"
+)
+
+// limitedBody streams at most limit bytes from r. Once the limit is reached it either reports io.EOF,
+// so the parser analyzes what was read, or a CodeBodyTooLarge error when failOnExceed is set.
+type limitedBody struct {
+	r            io.Reader
+	limit        int64
+	failOnExceed bool
+
+	read     int64
+	exceeded bool
+}
+
+func newLimitedBody(r io.Reader, limit int64, failOnExceed bool) *limitedBody {
+	return &limitedBody{r: r, limit: limit, failOnExceed: failOnExceed}
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.read >= l.limit {
+		return 0, l.probe()
+	}
+
+	if remaining := l.limit - l.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	return n, err
+}
+
+// probe reads a single byte past the limit to tell a body of exactly limit bytes apart from a larger one.
+func (l *limitedBody) probe() error {
+	var one [1]byte
+	for {
+		n, err := l.r.Read(one[:])
+		if n > 0 {
+			l.exceeded = true
+			if l.failOnExceed {
+				return newBodyTooLargeError(l.limit)
+			}
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func newBodyTooLargeError(limit int64) *AnalyzeError {
+	return &AnalyzeError{
+		Code:    CodeBodyTooLarge,
+		Message: fmt.Sprintf("the page is larger than the %d byte limit", limit),
+	}
+}
@@ -0,0 +1,137 @@
+package urlanalyzer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"io"
+	"mime"
+	"strings"
+)
+
+// charsetSniffLen is how much of the body the HTML spec allows us to look at before deciding on an encoding
+const charsetSniffLen = 1024
+
+var byteOrderMarks = []struct {
+	bom  []byte
+	name string
+}{
+	{[]byte{0xef, 0xbb, 0xbf}, "utf-8"},
+	{[]byte{0xfe, 0xff}, "utf-16be"},
+	{[]byte{0xff, 0xfe}, "utf-16le"},
+}
+
+// decodeBody works out the character encoding of an HTML body from its BOM, the Content-Type header and any
+// <meta> declaration, and returns a reader that transcodes the body to UTF-8 for html.Parse.
+func decodeBody(body io.Reader, contentType string) (io.Reader, model.Encoding, error) {
+	// reuses body as is when it is already a large enough *bufio.Reader
+	br := bufio.NewReaderSize(body, charsetSniffLen)
+	peek, err := br.Peek(charsetSniffLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, model.Encoding{}, err
+	}
+
+	info := model.Encoding{
+		ContentTypeCharset: contentTypeCharset(contentType),
+	}
+
+	bomLen := 0
+	for _, b := range byteOrderMarks {
+		if bytes.HasPrefix(peek, b.bom) {
+			info.BOM = b.name
+			bomLen = len(b.bom)
+			break
+		}
+	}
+
+	if label := metaCharset(peek[bomLen:]); label != "" {
+		info.MetaCharset = canonicalCharset(label)
+	}
+
+	// BOM > Content-Type > <meta> > sniffing, as the HTML spec prescribes
+	enc, name, _ := charset.DetermineEncoding(peek, contentType)
+	info.Detected = name
+	info.Conflict = charsetsConflict(info)
+
+	// the parser would otherwise see the BOM as a stray U+FEFF
+	if _, err := br.Discard(bomLen); err != nil {
+		return nil, info, err
+	}
+
+	if enc == encoding.Nop || name == "utf-8" {
+		return br, info, nil
+	}
+	return enc.NewDecoder().Reader(br), info, nil
+}
+
+func contentTypeCharset(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["charset"] == "" {
+		return ""
+	}
+	return canonicalCharset(params["charset"])
+}
+
+// canonicalCharset maps a label like "latin1" to its WHATWG name ("windows-1252"); unknown labels are kept as is.
+func canonicalCharset(label string) string {
+	if _, name := charset.Lookup(label); name != "" {
+		return name
+	}
+	return strings.ToLower(strings.TrimSpace(label))
+}
+
+// charsetsConflict reports whether two of the sources that declare an encoding disagree with each other.
+func charsetsConflict(info model.Encoding) bool {
+	var first string
+	for _, cs := range []string{info.BOM, info.ContentTypeCharset, info.MetaCharset} {
+		if cs == "" {
+			continue
+		}
+		if first == "" {
+			first = cs
+		} else if cs != first {
+			return true
+		}
+	}
+	return false
+}
+
+// metaCharset finds <meta charset> or <meta http-equiv="Content-Type" content="...; charset=..."> in the
+// first bytes of the document.
+func metaCharset(prefix []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(prefix))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			if token.DataAtom != atom.Meta {
+				continue
+			}
+
+			var httpEquiv, content string
+			for _, attr := range token.Attr {
+				switch strings.ToLower(attr.Key) {
+				case "charset":
+					return attr.Val
+				case "http-equiv":
+					httpEquiv = attr.Val
+				case "content":
+					content = attr.Val
+				}
+			}
+
+			if strings.EqualFold(httpEquiv, "content-type") {
+				if cs := contentTypeCharset(content); cs != "" {
+					return cs
+				}
+			}
+		}
+	}
+}
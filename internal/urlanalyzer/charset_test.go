@@ -0,0 +1,109 @@
+package urlanalyzer
+
+import (
+	"context"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnalyzePage_CharsetTranscoding(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		enc          encoding.Encoding
+		html         string
+		wantTitle    string
+		wantDetected string
+		wantConflict bool
+	}{
+		{
+			name:         "windows-1251 from Content-Type",
+			contentType:  "text/html; charset=windows-1251",
+			enc:          charmap.Windows1251,
+			html:         `<html><head><title>Привет мир</title></head></html>`,
+			wantTitle:    "Привет мир",
+			wantDetected: "windows-1251",
+		},
+		{
+			name:         "Shift_JIS from meta charset",
+			contentType:  "text/html",
+			enc:          japanese.ShiftJIS,
+			html:         `<html><head><meta charset="Shift_JIS"><title>こんにちは</title></head></html>`,
+			wantTitle:    "こんにちは",
+			wantDetected: "shift_jis",
+		},
+		{
+			name:         "ISO-8859-1 from http-equiv",
+			contentType:  "text/html",
+			enc:          charmap.ISO8859_1,
+			html:         `<html><head><meta http-equiv="Content-Type" content="text/html; charset=ISO-8859-1"><title>Café</title></head></html>`,
+			wantTitle:    "Café",
+			wantDetected: "windows-1252",
+		},
+		{
+			name:         "header wins over conflicting meta",
+			contentType:  "text/html; charset=windows-1251",
+			enc:          charmap.Windows1251,
+			html:         `<html><head><meta charset="utf-8"><title>Привет</title></head></html>`,
+			wantTitle:    "Привет",
+			wantDetected: "windows-1251",
+			wantConflict: true,
+		},
+	}
+
+	service := NewAnalyzer(httpClient)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := tc.enc.NewEncoder().String(tc.html)
+			if err != nil {
+				t.Fatalf("failed to encode fixture: %v", err)
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				_, _ = w.Write([]byte(body))
+			}))
+			defer ts.Close()
+
+			result, err := service.AnalyzePage(context.Background(), ts.URL, DefaultAnalyzeOptions())
+			if err != nil {
+				t.Fatalf("AnalyzePage failed: %v", err)
+			}
+
+			if result.PageTitle != tc.wantTitle {
+				t.Errorf("expected title %q, got %q", tc.wantTitle, result.PageTitle)
+			}
+			if result.Encoding.Detected != tc.wantDetected {
+				t.Errorf("expected detected encoding %q, got %q", tc.wantDetected, result.Encoding.Detected)
+			}
+			if result.Encoding.Conflict != tc.wantConflict {
+				t.Errorf("expected conflict %v, got %+v", tc.wantConflict, result.Encoding)
+			}
+		})
+	}
+}
+
+func TestAnalyzePage_UTF8BOMIsStripped(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("\xef\xbb\xbf<!DOCTYPE html><html><head><title>BOM</title></head></html>"))
+	}))
+	defer ts.Close()
+
+	result, err := NewAnalyzer(httpClient).AnalyzePage(context.Background(), ts.URL, DefaultAnalyzeOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	if result.Encoding.BOM != "utf-8" || result.Encoding.Detected != "utf-8" {
+		t.Errorf("expected utf-8 BOM to be detected, got %+v", result.Encoding)
+	}
+	if result.HTMLVersion != "HTML5" || result.PageTitle != "BOM" {
+		t.Errorf("expected the BOM not to disturb parsing, got version %q title %q", result.HTMLVersion, result.PageTitle)
+	}
+}
@@ -0,0 +1,60 @@
+package urlanalyzer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at
+const sniffLen = 512
+
+var htmlMediaTypes = map[string]bool{
+	"text/html":             true,
+	"application/xhtml+xml": true,
+}
+
+// detectMediaType returns the media type of the response, falling back to sniffing the body when the
+// Content-Type header is missing or malformed. body must buffer at least sniffLen bytes.
+// Non-HTML content is rejected with CodeNonHTMLContent, and a body that claims to be HTML but is
+// clearly binary with CodeContentMismatch.
+func detectMediaType(contentType string, body *bufio.Reader) (mediaType string, sniffed bool, err error) {
+	declared, _, parseErr := mime.ParseMediaType(contentType)
+
+	peek, peekErr := body.Peek(sniffLen)
+	if peekErr != nil && !errors.Is(peekErr, io.EOF) {
+		return "", false, peekErr
+	}
+	sniffedType, _, _ := mime.ParseMediaType(http.DetectContentType(peek))
+
+	if contentType == "" || parseErr != nil {
+		if !htmlMediaTypes[sniffedType] {
+			return sniffedType, true, &AnalyzeError{
+				Code:    CodeNonHTMLContent,
+				Message: fmt.Sprintf("the URL did not return an HTML page (detected %s)", sniffedType),
+			}
+		}
+		return sniffedType, true, nil
+	}
+
+	if !htmlMediaTypes[declared] {
+		return declared, false, &AnalyzeError{
+			Code:    CodeNonHTMLContent,
+			Message: fmt.Sprintf("the URL returned %s, only text/html and application/xhtml+xml can be analyzed", declared),
+		}
+	}
+
+	// everything textual is fine (XHTML sniffs as text/xml), but a PDF or an image labelled as HTML is not
+	if len(peek) > 0 && !strings.HasPrefix(sniffedType, "text/") && !htmlMediaTypes[sniffedType] {
+		return declared, false, &AnalyzeError{
+			Code:    CodeContentMismatch,
+			Message: fmt.Sprintf("the URL claims to return %s but the content looks like %s", declared, sniffedType),
+		}
+	}
+
+	return declared, false, nil
+}
@@ -0,0 +1,147 @@
+package urlanalyzer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzePage_ContentType(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		wantCode      ErrorCode
+		wantMediaType string
+		wantSniffed   bool
+	}{
+		{
+			name:          "html",
+			contentType:   "text/html; charset=utf-8",
+			body:          `<html><head><title>ok</title></head></html>`,
+			wantMediaType: "text/html",
+		},
+		{
+			name:          "xhtml",
+			contentType:   "application/xhtml+xml",
+			body:          `<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"><head><title>ok</title></head></html>`,
+			wantMediaType: "application/xhtml+xml",
+		},
+		{
+			name:          "missing header is sniffed",
+			body:          `<!DOCTYPE html><html><head><title>ok</title></head></html>`,
+			wantMediaType: "text/html",
+			wantSniffed:   true,
+		},
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"hello":"world"}`,
+			wantCode:    CodeNonHTMLContent,
+		},
+		{
+			name:     "sniffed pdf",
+			body:     "%PDF-1.7\n...",
+			wantCode: CodeNonHTMLContent,
+		},
+		{
+			name:        "binary labelled as html",
+			contentType: "text/html",
+			body:        "\x89PNG\x0d\x0a\x1a\x0a\x00\x00\x00\x0dIHDR",
+			wantCode:    CodeContentMismatch,
+		},
+	}
+
+	service := NewAnalyzer(httpClient)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// a nil value stops net/http from sniffing a Content-Type of its own
+				w.Header()["Content-Type"] = nil
+				if tc.contentType != "" {
+					w.Header().Set("Content-Type", tc.contentType)
+				}
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer ts.Close()
+
+			result, err := service.AnalyzePage(context.Background(), ts.URL, DefaultAnalyzeOptions())
+
+			if tc.wantCode != "" {
+				var analyzeErr *AnalyzeError
+				if !errors.As(err, &analyzeErr) || analyzeErr.Code != tc.wantCode {
+					t.Fatalf("expected %q error, got %v", tc.wantCode, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("AnalyzePage failed: %v", err)
+			}
+			if result.Content.MediaType != tc.wantMediaType || result.Content.Sniffed != tc.wantSniffed {
+				t.Errorf("expected media type %q (sniffed %v), got %+v", tc.wantMediaType, tc.wantSniffed, result.Content)
+			}
+			if result.Content.ContentLength != int64(len(tc.body)) {
+				t.Errorf("expected content length %d, got %d", len(tc.body), result.Content.ContentLength)
+			}
+			if result.PageTitle != "ok" {
+				t.Errorf("expected title to be parsed, got %q", result.PageTitle)
+			}
+		})
+	}
+}
+
+func TestAnalyzePage_BodySizeLimit(t *testing.T) {
+	page := `<html><head><title>big</title></head><body>` + strings.Repeat("<p>filler</p>", 1000) + `<h1>late</h1></body></html>`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		// flushing before writing forces a chunked response without Content-Length
+		if r.URL.Query().Has("chunked") {
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write([]byte(page))
+	}))
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	t.Run("truncates", func(t *testing.T) {
+		result, err := service.AnalyzePage(context.Background(), ts.URL, AnalyzeOptions{MaxBodyBytes: 2048})
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if !result.Content.Truncated || result.Content.BytesRead != 2048 {
+			t.Errorf("expected truncation at 2048 bytes, got %+v", result.Content)
+		}
+		if result.PageTitle != "big" || result.Headings.H1 != 0 {
+			t.Errorf("expected only the first 2048 bytes to be analyzed, got title %q and %d H1", result.PageTitle, result.Headings.H1)
+		}
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		result, err := service.AnalyzePage(context.Background(), ts.URL, AnalyzeOptions{MaxBodyBytes: int64(len(page))})
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if result.Content.Truncated || result.Content.BytesRead != int64(len(page)) || result.Headings.H1 != 1 {
+			t.Errorf("expected the whole page to be analyzed, got %+v", result.Content)
+		}
+	})
+
+	for _, query := range []string{"", "?chunked"} {
+		t.Run("hard fail"+query, func(t *testing.T) {
+			opts := AnalyzeOptions{MaxBodyBytes: 2048, FailOnBodyTooLarge: true}
+			_, err := service.AnalyzePage(context.Background(), ts.URL+query, opts)
+
+			var analyzeErr *AnalyzeError
+			if !errors.As(err, &analyzeErr) || analyzeErr.Code != CodeBodyTooLarge {
+				t.Fatalf("expected %q error, got %v", CodeBodyTooLarge, err)
+			}
+		})
+	}
+}
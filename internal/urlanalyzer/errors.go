@@ -0,0 +1,120 @@
+package urlanalyzer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"github.com/sendurangr/url-analyzer-api/internal/netguard"
+	"net"
+	"net/http"
+)
+
+// ErrorCode is the stable, machine-readable identifier of an AnalyzeError. Clients branch on it, so never rename one.
+type ErrorCode string
+
+const (
+	CodeUpstreamStatus   ErrorCode = "upstream_http_status"
+	CodeDNSFailure       ErrorCode = "dns_failure"
+	CodeTLSFailure       ErrorCode = "tls_failure"
+	CodeTimeout          ErrorCode = "timeout"
+	CodeConnectionFailed ErrorCode = "connection_failed"
+	CodeRedirectLoop     ErrorCode = "redirect_loop"
+	CodeTooManyRedirects ErrorCode = "too_many_redirects"
+	CodeNonHTMLContent   ErrorCode = "non_html_content"
+	CodeContentMismatch  ErrorCode = "content_mismatch"
+	CodeBodyTooLarge     ErrorCode = "body_too_large"
+	CodeParseFailure     ErrorCode = "parse_failure"
+	CodeBlockedTarget    ErrorCode = "blocked_target"
+	CodeRobotsDisallowed ErrorCode = "robots_disallowed"
+	CodeCanceled         ErrorCode = "canceled"
+	CodeInternal         ErrorCode = "internal_error"
+)
+
+// ErrAnalysisCanceled is returned when the caller's context is canceled before the analysis completes.
+var ErrAnalysisCanceled error = &AnalyzeError{Code: CodeCanceled, Message: "analysis canceled by the caller"}
+
+// AnalyzeError is the typed error returned by AnalyzePage. Use errors.As to get at it.
+type AnalyzeError struct {
+	Code ErrorCode
+	// Message is a short, human readable summary; Error() appends the underlying cause
+	Message string
+	// UpstreamStatus is the status code returned by the analyzed page, when there was one
+	UpstreamStatus int
+	// Retryable tells the client whether the same request may succeed later
+	Retryable bool
+	// Redirects is the chain followed before giving up, only set for CodeRedirectLoop and CodeTooManyRedirects
+	Redirects *model.Redirects
+	Err       error
+}
+
+func (e *AnalyzeError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *AnalyzeError) Unwrap() error {
+	return e.Err
+}
+
+func newAnalyzeError(code ErrorCode, message string, err error) *AnalyzeError {
+	return &AnalyzeError{Code: code, Message: message, Err: err}
+}
+
+func newUpstreamStatusError(status int) *AnalyzeError {
+	return &AnalyzeError{
+		Code: CodeUpstreamStatus,
+		Message: fmt.Sprintf("HTTP error %d: %s — the URL is unreachable or returned an error",
+			status, http.StatusText(status)),
+		UpstreamStatus: status,
+		Retryable:      status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500,
+	}
+}
+
+// classifyRequestError turns a transport level error from fetching the page into an AnalyzeError.
+func classifyRequestError(err error) *AnalyzeError {
+	var analyzeErr *AnalyzeError
+	if errors.As(err, &analyzeErr) {
+		return analyzeErr
+	}
+
+	var (
+		blockedErr  *netguard.BlockedError
+		dnsErr      *net.DNSError
+		certErr     *tls.CertificateVerificationError
+		alertErr    tls.AlertError
+		recordErr   tls.RecordHeaderError
+		unknownCA   x509.UnknownAuthorityError
+		hostnameErr x509.HostnameError
+		invalidCert x509.CertificateInvalidError
+		netErr      net.Error
+	)
+
+	switch {
+	case errors.As(err, &blockedErr):
+		return newAnalyzeError(CodeBlockedTarget, "the URL points to an address that may not be analyzed", err)
+	case errors.Is(err, ErrRedirectLoop):
+		return newAnalyzeError(CodeRedirectLoop, "the URL redirects in a loop", err)
+	case errors.Is(err, ErrTooManyRedirects):
+		return newAnalyzeError(CodeTooManyRedirects, "the URL redirects too many times", err)
+	case errors.As(err, &dnsErr):
+		e := newAnalyzeError(CodeDNSFailure, "the host name could not be resolved", err)
+		e.Retryable = !dnsErr.IsNotFound
+		return e
+	case errors.As(err, &certErr), errors.As(err, &alertErr), errors.As(err, &recordErr),
+		errors.As(err, &unknownCA), errors.As(err, &hostnameErr), errors.As(err, &invalidCert):
+		return newAnalyzeError(CodeTLSFailure, "the TLS handshake with the host failed", err)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		e := newAnalyzeError(CodeTimeout, "the URL took too long to respond", err)
+		e.Retryable = true
+		return e
+	default:
+		e := newAnalyzeError(CodeConnectionFailed, "failed to perform request", err)
+		e.Retryable = true
+		return e
+	}
+}
@@ -0,0 +1,115 @@
+package urlanalyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"github.com/sendurangr/url-analyzer-api/internal/utils"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	// ErrRedirectLoop is returned when a redirect points back to a URL already visited in the chain.
+	ErrRedirectLoop = errors.New("redirect loop detected")
+	// ErrTooManyRedirects is returned when the chain is longer than AnalyzeOptions.MaxRedirects.
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// fetchPage GETs rawURL and follows redirects by hand, so that every hop can be recorded in result.Redirects
+// and checked against robots.txt. The returned URL is the one that produced the final (non-redirect) response.
+func (a *analyzer) fetchPage(ctx context.Context, rawURL string, opts AnalyzeOptions, result *model.AnalyzerResult) (*http.Response, *url.URL, error) {
+	currentURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, newAnalyzeError(CodeInternal, "failed to parse URL", err)
+	}
+
+	visited := map[string]bool{currentURL.String(): true}
+	redirects := &result.Redirects
+
+	for {
+		if opts.RespectRobots {
+			rules, err := a.robots.rulesFor(ctx, currentURL, opts.userAgent())
+			if err != nil {
+				// the page would fail the same way, report it as such rather than as a robots.txt verdict
+				return nil, nil, err
+			}
+			if !rules.allowed(opts.RobotsUserAgent, currentURL) {
+				return nil, nil, &AnalyzeError{
+					Code:    CodeRobotsDisallowed,
+					Message: fmt.Sprintf("robots.txt disallows fetching %s for %q", currentURL, opts.RobotsUserAgent),
+				}
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, currentURL.String(), nil)
+		if err != nil {
+			return nil, nil, newAnalyzeError(CodeInternal, "creating HTTP request", err)
+		}
+
+		utils.SetHeaders(req)
+		identify(req, opts.userAgent())
+
+		start := time.Now()
+		resp, err := a.pageClient.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hop := model.RedirectHop{
+			URL:        currentURL.String(),
+			StatusCode: resp.StatusCode,
+			LatencyMs:  time.Since(start).Milliseconds(),
+		}
+
+		nextURL, err := resp.Location()
+		if !isRedirectStatus(resp.StatusCode) || err != nil {
+			// final response - the caller owns the body from here
+			redirects.Chain = append(redirects.Chain, hop)
+			redirects.FinalURL = currentURL.String()
+			return resp, currentURL, nil
+		}
+
+		hop.Location = resp.Header.Get("Location")
+		redirects.Chain = append(redirects.Chain, hop)
+		redirects.Count++
+		discardBody(resp)
+
+		if currentURL.Scheme == "https" && nextURL.Scheme == "http" {
+			redirects.HTTPSDowngrade = true
+		}
+
+		if visited[nextURL.String()] {
+			redirects.Loop = true
+			return nil, nil, fmt.Errorf("%w: %s redirects back to %s", ErrRedirectLoop, currentURL, nextURL)
+		}
+		if redirects.Count > *opts.MaxRedirects {
+			redirects.TooManyRedirects = true
+			return nil, nil, fmt.Errorf("%w: stopped after %d hops at %s", ErrTooManyRedirects, *opts.MaxRedirects, currentURL)
+		}
+
+		visited[nextURL.String()] = true
+		currentURL = nextURL
+	}
+}
+
+func isRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// discardBody drains and closes an intermediate response so the underlying connection can be reused.
+func discardBody(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if err := resp.Body.Close(); err != nil {
+		slog.Error("Failed to close response body", "error", err)
+	}
+}
@@ -0,0 +1,161 @@
+package urlanalyzer
+
+import (
+	"context"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"github.com/sendurangr/url-analyzer-api/internal/utils"
+	"golang.org/x/net/html"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// fragmentRef is a link that points at a #fragment.
+type fragmentRef struct {
+	href string
+	text string
+	// page is the normalized URL of the document the fragment points into
+	page     string
+	fragment string
+}
+
+// validateFragments checks that the fragment links of the page point at an existing id or anchor name. Fragments
+// into other internal pages are only verified when opts.CheckInternalFragments is set, by fetching those pages within
+// the link-check run of the page.
+func (a *analyzer) validateFragments(ctx context.Context, pl *pageLinks, pageURL *url.URL, run *linkCheckRun, opts AnalyzeOptions) model.FragmentLinks {
+	var report model.FragmentLinks
+	self := normalizeLinkURL(pageURL)
+
+	// fragments grouped by the other page they point into, so each page is fetched once
+	var pages []string
+	remote := make(map[string][]fragmentRef)
+
+	for _, f := range pl.fragments {
+		switch {
+		case f.page == self:
+			checkFragment(f, pl.targets, &report)
+		case opts.CheckInternalFragments && !opts.SkipLinkCheck && run.site.isInternalLink(f.page):
+			if _, ok := remote[f.page]; !ok {
+				pages = append(pages, f.page)
+			}
+			remote[f.page] = append(remote[f.page], f)
+		default:
+			report.Unchecked++
+		}
+	}
+	if len(pages) == 0 {
+		return report
+	}
+
+	var wg sync.WaitGroup
+	targets := make([]map[string]bool, len(pages))
+	// timedOut marks the pages the link-check budget ran out on
+	timedOut := make([]bool, len(pages))
+
+	for i, page := range pages {
+		wg.Add(1)
+		go func(i int, page string) {
+			defer wg.Done()
+			pageURL, err := url.Parse(page)
+			if err != nil {
+				return
+			}
+			delay, allowed, err := a.hostDelay(ctx, pageURL, opts)
+			// robots.txt that could not be fetched in time says nothing about the page
+			if ctx.Err() != nil {
+				timedOut[i] = true
+				return
+			}
+			if err != nil || !allowed {
+				return
+			}
+			err = run.politely(ctx, pageURL.Host, delay, func() {
+				targets[i] = a.fetchFragmentTargets(ctx, page, opts)
+			})
+			timedOut[i] = err != nil || (targets[i] == nil && ctx.Err() != nil)
+		}(i, page)
+	}
+	wg.Wait()
+
+	for i, page := range pages {
+		for _, f := range remote[page] {
+			// the page itself being broken is reported by the link check
+			if targets[i] == nil {
+				report.Unchecked++
+				if timedOut[i] {
+					report.UncheckedDueToTimeout++
+				}
+				continue
+			}
+			checkFragment(f, targets[i], &report)
+		}
+	}
+	return report
+}
+
+func checkFragment(f fragmentRef, targets map[string]bool, report *model.FragmentLinks) {
+	report.Checked++
+	// browsers scroll to the top for #top even without a matching element
+	if targets[f.fragment] || strings.EqualFold(f.fragment, "top") {
+		return
+	}
+	report.Broken = append(report.Broken, model.BrokenFragment{
+		Href:     f.href,
+		Text:     f.text,
+		URL:      f.page,
+		Fragment: f.fragment,
+	})
+}
+
+// fetchFragmentTargets downloads an HTML page and returns its fragment targets, nil when the page could not be
+// fetched or is not HTML.
+func (a *analyzer) fetchFragmentTargets(ctx context.Context, page string, opts AnalyzeOptions) map[string]bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, page, nil)
+	if err != nil {
+		return nil
+	}
+	utils.SetHeaders(req)
+	identify(req, opts.userAgent())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Error("Failed to close response body", "error", err)
+		}
+	}()
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); resp.StatusCode >= 400 || !htmlMediaTypes[mediaType] {
+		return nil
+	}
+
+	body, _, err := decodeBody(io.LimitReader(resp.Body, opts.MaxBodyBytes), contentType)
+	if err != nil {
+		return nil
+	}
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil
+	}
+
+	targets := make(map[string]bool)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			collectFragmentTargets(n, targets)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return targets
+}
@@ -0,0 +1,172 @@
+package urlanalyzer
+
+import (
+	"context"
+	"fmt"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAnalyzePage_FragmentLinks(t *testing.T) {
+	var ts *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprintf(w, `<html><body>
+			<nav>
+				<a href="#intro">Intro</a>
+				<a href="#usage">Usage</a>
+				<a href="#top">Back to top</a>
+				<a href="#">Nothing</a>
+				<a href="%s/docs#legacy">Legacy</a>
+				<a href="/guide#install">Install</a>
+				<a href="/guide#uninstall">Uninstall</a>
+				<a href="https://elsewhere.example/#anything">Elsewhere</a>
+			</nav>
+			<h2 id="intro">Intro</h2>
+			<a name="legacy"></a>
+		</body></html>`, ts.URL)
+	})
+	mux.HandleFunc("/guide", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, `<html><body><section id="install">Install</section></body></html>`)
+	})
+	ts = httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	t.Run("same page only", func(t *testing.T) {
+		result, err := service.AnalyzePage(context.Background(), ts.URL+"/docs", AnalyzeOptions{SkipLinkCheck: true})
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+
+		want := []model.BrokenFragment{{Href: "#usage", Text: "Usage", URL: ts.URL + "/docs", Fragment: "usage"}}
+		assertFragments(t, result.FragmentLinks, 4, 3, want)
+
+		// in-page anchors are not links to other resources, the rest are /docs and /guide
+		if result.InternalLinks != 2 {
+			t.Errorf("expected 2 internal links, got %d", result.InternalLinks)
+		}
+	})
+
+	t.Run("other internal pages", func(t *testing.T) {
+		result, err := service.AnalyzePage(context.Background(), ts.URL+"/docs", AnalyzeOptions{CheckInternalFragments: true})
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+
+		want := []model.BrokenFragment{
+			{Href: "#usage", Text: "Usage", URL: ts.URL + "/docs", Fragment: "usage"},
+			{Href: "/guide#uninstall", Text: "Uninstall", URL: ts.URL + "/guide", Fragment: "uninstall"},
+		}
+		assertFragments(t, result.FragmentLinks, 6, 1, want)
+	})
+}
+
+func TestAnalyzePage_FragmentsShareLinkCheckRun(t *testing.T) {
+	page := `<html><body><a href="/guide#install">Install</a><a href="/faq#billing">Billing</a></body></html>`
+
+	t.Run("host delay", func(t *testing.T) {
+		var mu sync.Mutex
+		// when requests are sent, the server sees them later the first time a connection has to be dialed
+		var requests []time.Time
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/page" {
+				_, _ = fmt.Fprint(w, page)
+			}
+		}))
+		defer ts.Close()
+
+		client := *httpClient
+		client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.Path != "/page" {
+				mu.Lock()
+				requests = append(requests, time.Now())
+				mu.Unlock()
+			}
+			return http.DefaultTransport.RoundTrip(r)
+		})
+		service := NewAnalyzer(&client)
+
+		delay := 50 * time.Millisecond
+		_, err := service.AnalyzePage(context.Background(), ts.URL+"/page", AnalyzeOptions{CheckInternalFragments: true, HostDelay: delay})
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		// two link checks and two fragment page fetches, all against one host
+		if len(requests) != 4 {
+			t.Fatalf("expected 4 requests, got %d", len(requests))
+		}
+		for i := 1; i < len(requests); i++ {
+			// a little slack for timer granularity
+			if gap := requests[i].Sub(requests[i-1]); gap < delay-5*time.Millisecond {
+				t.Errorf("expected requests to be spaced out by the host delay, request %d came %v after the previous one", i, gap)
+			}
+		}
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		release := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/page" {
+				_, _ = fmt.Fprint(w, page)
+				return
+			}
+			// the links themselves check out, only fetching the pages for their fragments runs out of time
+			if r.Method == http.MethodHead {
+				return
+			}
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer ts.Close()
+		defer close(release)
+
+		service := NewAnalyzer(httpClient)
+
+		budget := 200 * time.Millisecond
+		start := time.Now()
+		result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", AnalyzeOptions{CheckInternalFragments: true, LinkCheckTimeout: budget})
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+
+		// a budget of their own would let the fragment pages run for another linkCheckTimeoutMs after the links
+		if elapsed := time.Since(start); elapsed > budget+budget/2 {
+			t.Errorf("expected fragments to share the link-check budget, took %v", elapsed)
+		}
+		if got := result.FragmentLinks; got.Checked != 0 || got.Unchecked != 2 || got.UncheckedDueToTimeout != 2 {
+			t.Errorf("expected both fragments to be unchecked due to the timeout, got %+v", got)
+		}
+		if result.UncheckedDueToTimeout != 0 || !result.Partial {
+			t.Errorf("expected fragments that ran out of time to mark the result as partial, got %d links unchecked due to the timeout and partial %v", result.UncheckedDueToTimeout, result.Partial)
+		}
+	})
+}
+
+func assertFragments(t *testing.T, got model.FragmentLinks, checked, unchecked int, broken []model.BrokenFragment) {
+	t.Helper()
+
+	if got.Checked != checked || got.Unchecked != unchecked {
+		t.Errorf("expected %d checked and %d unchecked fragments, got %+v", checked, unchecked, got)
+	}
+	if len(got.Broken) != len(broken) {
+		t.Fatalf("expected broken fragments %+v, got %+v", broken, got.Broken)
+	}
+	for i := range broken {
+		if got.Broken[i] != broken[i] {
+			t.Errorf("broken fragment %d: expected %+v, got %+v", i, broken[i], got.Broken[i])
+		}
+	}
+}
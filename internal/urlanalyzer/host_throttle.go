@@ -0,0 +1,85 @@
+package urlanalyzer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hostThrottle keeps link checks polite towards each host: it bounds the number of in-flight requests per host,
+// spaces requests to the same host out by a minimum delay, and lets a host push everything back after a 429/503.
+type hostThrottle struct {
+	perHost int
+
+	mu    sync.Mutex
+	next  map[string]time.Time
+	slots map[string]chan struct{}
+}
+
+func newHostThrottle(perHost int) *hostThrottle {
+	return &hostThrottle{
+		perHost: perHost,
+		next:    make(map[string]time.Time),
+		slots:   make(map[string]chan struct{}),
+	}
+}
+
+// acquire takes one of the host's concurrency slots, blocking until one is free or ctx is done.
+// The returned func gives the slot back.
+func (t *hostThrottle) acquire(ctx context.Context, host string) (release func(), err error) {
+	t.mu.Lock()
+	slots, ok := t.slots[host]
+	if !ok {
+		slots = make(chan struct{}, t.perHost)
+		t.slots[host] = slots
+	}
+	t.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// wait reserves the next free start time for host, delay after the previous one, and blocks until it comes up.
+func (t *hostThrottle) wait(ctx context.Context, host string, delay time.Duration) error {
+	t.mu.Lock()
+	now := time.Now()
+	slot := t.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	t.next[host] = slot.Add(delay)
+	t.mu.Unlock()
+
+	return sleepContext(ctx, slot.Sub(now))
+}
+
+// backoff keeps every request to host from starting for at least d.
+func (t *hostThrottle) backoff(host string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(t.next[host]) {
+		t.next[host] = until
+	}
+}
+
+// sleepContext sleeps for d, returning early with ctx's error when ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,44 @@
+package urlanalyzer
+
+import (
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"slices"
+	"strings"
+)
+
+// auditLinkAttributes counts the rel and target usages SEO and security reviews look for. It runs over every
+// occurrence of every HTTP link, since each anchor carries its own attributes.
+func auditLinkAttributes(links []linkRef, site *sameSite) model.LinkAttributes {
+	var audit model.LinkAttributes
+
+	for _, link := range links {
+		if !isHTTPLink(link.url) {
+			continue
+		}
+		internal := site.isInternalLink(link.url)
+
+		if slices.Contains(link.rel, "nofollow") {
+			if internal {
+				audit.NofollowInternal++
+			} else {
+				audit.NofollowExternal++
+			}
+		}
+		if slices.Contains(link.rel, "sponsored") {
+			audit.Sponsored++
+		}
+		if slices.Contains(link.rel, "ugc") {
+			audit.UGC++
+		}
+
+		if strings.EqualFold(link.target, "_blank") {
+			audit.TargetBlank++
+			// noreferrer implies noopener
+			if !internal && !slices.Contains(link.rel, "noopener") && !slices.Contains(link.rel, "noreferrer") {
+				audit.BlankWithoutNoopener++
+			}
+		}
+	}
+
+	return audit
+}
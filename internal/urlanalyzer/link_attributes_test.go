@@ -0,0 +1,46 @@
+package urlanalyzer
+
+import (
+	"context"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"testing"
+)
+
+func TestAnalyzePage_LinkAttributes(t *testing.T) {
+	html := `<html><body>
+		<a href="/login" rel="nofollow">Log in</a>
+		<a href="/login" rel="NoFollow">Log in again</a>
+		<a href="https://partner.example/" rel="sponsored nofollow" target="_blank">Partner</a>
+		<a href="https://forum.example/" rel="ugc" target="_blank" >Forum</a>
+		<a href="https://safe.example/" rel="noopener" target="_blank">Safe</a>
+		<a href="https://private.example/" rel="noreferrer" target="_BLANK">Private</a>
+		<a href="/help" target="_blank">Help</a>
+		<a href="mailto:info@example.com" rel="nofollow" target="_blank">Mail</a>
+	</body></html>`
+
+	ts := startTestServer(html)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	result, err := service.AnalyzePage(context.Background(), ts.URL, AnalyzeOptions{SkipLinkCheck: true, IncludeLinkDetails: true})
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	want := model.LinkAttributes{
+		NofollowInternal:     2,
+		NofollowExternal:     1,
+		Sponsored:            1,
+		UGC:                  1,
+		TargetBlank:          5,
+		BlankWithoutNoopener: 2,
+	}
+	if result.LinkAttributes != want {
+		t.Errorf("expected %+v, got %+v", want, result.LinkAttributes)
+	}
+
+	if got := result.Links[1]; got.Rel != "sponsored nofollow" || got.Target != "_blank" {
+		t.Errorf("expected rel and target in the link report, got %+v", got)
+	}
+}
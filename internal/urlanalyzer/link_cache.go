@@ -0,0 +1,58 @@
+package urlanalyzer
+
+import (
+	"github.com/sendurangr/url-analyzer-api/internal/constants"
+	"sync"
+	"time"
+)
+
+// linkCache remembers link check outcomes across analyses running on the same analyzer, so that links shared by
+// many pages of a site (navigation, footer) are not re-checked for every page.
+type linkCache struct {
+	mu       sync.Mutex
+	outcomes *ttlCache[linkOutcome]
+}
+
+func newLinkCache() *linkCache {
+	return &linkCache{outcomes: newTTLCache[linkOutcome](constants.LinkCacheMaxEntries)}
+}
+
+// linkCacheVariant describes the options that change a link's outcome. A HEAD-only verdict says nothing about a GET,
+// and an outcome checked without soft-404 detection says nothing about whether the link is a soft 404.
+func linkCacheVariant(opts AnalyzeOptions) string {
+	if opts.DetectSoft404 {
+		return string(opts.LinkCheckMethod) + "+soft404"
+	}
+	return string(opts.LinkCheckMethod)
+}
+
+func linkCacheKey(variant string, link string) string {
+	return variant + " " + link
+}
+
+// get returns the cached outcome of checking link with the options variant, if it has not expired.
+func (c *linkCache) get(variant string, link string) (linkOutcome, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.outcomes.get(linkCacheKey(variant, link))
+}
+
+// put caches a definitive outcome: accessible links for constants.LinkCacheSuccessTTL, inaccessible ones for
+// constants.LinkCacheFailureTTL. Anything else (rate limited, skipped) is not a property of the link and is dropped.
+func (c *linkCache) put(variant string, outcome linkOutcome) {
+	var ttl time.Duration
+	switch outcome.status {
+	case linkAccessible:
+		ttl = constants.LinkCacheSuccessTTL
+	case linkInaccessible:
+		ttl = constants.LinkCacheFailureTTL
+	default:
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.outcomes.put(linkCacheKey(variant, outcome.link.url), outcome, ttl)
+}
@@ -0,0 +1,81 @@
+package urlanalyzer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAnalyzePage_LinkCache(t *testing.T) {
+	var linkRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<html><body><a href="/ok">Fine</a><a href="/missing">Gone</a></body></html>`)
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		linkRequests.Add(1)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		linkRequests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+	opts := AnalyzeOptions{LinkCheckMethod: LinkCheckHead, IncludeLinkDetails: true}
+
+	analyze := func(opts AnalyzeOptions) (hits int, requests int32) {
+		t.Helper()
+		linkRequests.Store(0)
+		result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts)
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if result.InaccessibleInternalLinks != 1 {
+			t.Errorf("expected the cached verdicts to be kept, got %+v", result)
+		}
+		for _, link := range result.Links {
+			if link.Cached != (result.LinkCacheHits > 0) {
+				t.Errorf("expected cached to be reported per link, got %+v", link)
+			}
+		}
+		return result.LinkCacheHits, linkRequests.Load()
+	}
+
+	if hits, requests := analyze(opts); hits != 0 || requests != 2 {
+		t.Errorf("first analysis: expected 0 hits and 2 requests, got %d and %d", hits, requests)
+	}
+	if hits, requests := analyze(opts); hits != 2 || requests != 0 {
+		t.Errorf("second analysis: expected 2 hits and no requests, got %d and %d", hits, requests)
+	}
+
+	bypass := opts
+	bypass.BypassLinkCache = true
+	if hits, requests := analyze(bypass); hits != 0 || requests != 2 {
+		t.Errorf("bypassed cache: expected 0 hits and 2 requests, got %d and %d", hits, requests)
+	}
+
+	// a different method is a different verdict
+	get := opts
+	get.LinkCheckMethod = LinkCheckGet
+	if hits, requests := analyze(get); hits != 0 || requests != 2 {
+		t.Errorf("other method: expected 0 hits and 2 requests, got %d and %d", hits, requests)
+	}
+}
+
+func TestLinkCache_OnlyDefinitiveOutcomes(t *testing.T) {
+	cache := newLinkCache()
+
+	for _, status := range []linkStatus{linkAccessible, linkInaccessible, linkRateLimited, linkSkippedRobots, linkUnchecked} {
+		cache.put(string(LinkCheckHead), linkOutcome{link: linkRef{url: status.String()}, status: status})
+
+		_, ok := cache.get(string(LinkCheckHead), status.String())
+		if want := status == linkAccessible || status == linkInaccessible; ok != want {
+			t.Errorf("%s: expected cached=%v, got %v", status, want, ok)
+		}
+	}
+}
@@ -2,86 +2,501 @@ package urlanalyzer
 
 import (
 	"context"
+	"errors"
 	"github.com/sendurangr/url-analyzer-api/internal/constants"
 	"github.com/sendurangr/url-analyzer-api/internal/model"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
-func (a *analyzer) checkLinksConcurrently(links []string, baseURL *url.URL, result *model.AnalyzerResult) {
-	var wg sync.WaitGroup
-	ctx, cancel := context.WithTimeout(context.Background(), constants.ContextTimeout)
-	defer cancel()
+// linkStatus is the verdict of checking a single link.
+type linkStatus int
+
+const (
+	linkAccessible linkStatus = iota
+	linkInaccessible
+	// linkSkippedRobots links were not requested because robots.txt disallows them
+	linkSkippedRobots
+	// linkUnchecked links have no verdict: link checking was skipped, capped or ran out of time, see uncheckedReason
+	linkUnchecked
+	// linkNonHTTP links use a scheme like mailto: or tel: that cannot be checked over HTTP
+	linkNonHTTP
+	// linkRateLimited links kept answering 429 (or 503 with Retry-After) after backing off, so their state is unknown
+	linkRateLimited
+)
 
-	type linkResult struct {
-		isInternal   bool
-		isAccessible bool
+func (s linkStatus) String() string {
+	switch s {
+	case linkAccessible:
+		return "accessible"
+	case linkInaccessible:
+		return "inaccessible"
+	case linkSkippedRobots:
+		return "skipped_robots"
+	case linkRateLimited:
+		return "rate_limited"
+	case linkNonHTTP:
+		return "non_http"
+	default:
+		return "unchecked"
 	}
+}
 
-	resultsChan := make(chan linkResult, len(links))
+// Reasons a link was left unchecked
+const (
+	uncheckedSkipLinkCheck = "skip_link_check"
+	uncheckedMaxLinks      = "max_links"
+	// uncheckedTimeout links were still pending, or in flight, when the link-check budget ran out
+	uncheckedTimeout = "timeout"
+)
 
-	// Limit the number of concurrent requests to avoid overwhelming the server
-	sem := make(chan struct{}, constants.LinkCheckerConcurrentLimit)
+// Error categories reported for inaccessible links
+const (
+	linkErrorDNS     = "dns"
+	linkErrorTLS     = "tls"
+	linkErrorTimeout = "timeout"
+	linkErrorRefused = "refused"
+	// linkErrorConnection covers every other transport failure: resets, EOF, malformed responses
+	linkErrorConnection  = "connection"
+	linkErrorBlocked     = "blocked"
+	linkErrorRedirects   = "redirects"
+	linkErrorInvalid     = "invalid_url"
+	linkErrorClientError = "4xx"
+	linkErrorServerError = "5xx"
+)
 
-	for _, link := range links {
-		wg.Add(1)
-		go func(link string) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+type linkOutcome struct {
+	link       linkRef
+	isInternal bool
+	status     linkStatus
+	statusCode int
+	method     string
+	errorCat   string
+	latency    time.Duration
+	// retryAfter is the server's Retry-After on a 429/503, zero when absent
+	retryAfter time.Duration
+	// finalURL is where the link ended up after redirects, empty when it was not redirected
+	finalURL string
+	// cached is set when the outcome was taken from the link cache instead of checking the link
+	cached          bool
+	uncheckedReason string
+	// soft404 is set for accessible links that serve an error page, only detected when asked for
+	soft404 bool
+}
 
-			isInternal, isAccessible := a.checkSingleLink(ctx, link, baseURL)
-			resultsChan <- linkResult{isInternal, isAccessible}
-		}(link)
+// classifyLinks marks links as internal or external without requesting them, leaving them unchecked for reason.
+func classifyLinks(links []linkRef, site *sameSite, reason string) []linkOutcome {
+	outcomes := make([]linkOutcome, len(links))
+	for i, link := range links {
+		outcomes[i] = linkOutcome{
+			link:            link,
+			isInternal:      site.isInternalLink(link.url),
+			status:          linkUnchecked,
+			uncheckedReason: reason,
+		}
 	}
+	return outcomes
+}
 
-	wg.Wait()
-	close(resultsChan)
+// tallyLinks adds the link outcomes to the result counters and, when requested, to the per-link report.
+func tallyLinks(outcomes []linkOutcome, result *model.AnalyzerResult, includeDetails bool) {
+	result.UniqueLinks += len(outcomes)
+	for _, o := range outcomes {
+		result.TotalLinks += o.link.occurrences
+		if o.link.occurrences > 1 {
+			result.RepeatedLinks = append(result.RepeatedLinks, model.LinkOccurrence{URL: o.link.url, Count: o.link.occurrences})
+		}
 
-	for r := range resultsChan {
-		if r.isInternal {
+		if o.cached {
+			result.LinkCacheHits++
+		}
+		if o.soft404 {
+			result.Soft404Links++
+		}
+		if o.status == linkNonHTTP {
+			tallyNonHTTPLink(o.link, result)
+		} else if o.isInternal {
 			result.InternalLinks++
-			if !r.isAccessible {
-				result.InaccessibleInternalLinks++
-			}
 		} else {
 			result.ExternalLinks++
-			if !r.isAccessible {
+		}
+
+		switch o.status {
+		case linkInaccessible:
+			if o.isInternal {
+				result.InaccessibleInternalLinks++
+			} else {
 				result.InaccessibleExternalLinks++
 			}
+		case linkSkippedRobots:
+			result.RobotsSkippedLinks++
+		case linkRateLimited:
+			result.RateLimitedLinks++
+		case linkUnchecked:
+			result.UncheckedLinks++
+			switch o.uncheckedReason {
+			case uncheckedTimeout:
+				result.UncheckedDueToTimeout++
+			case uncheckedMaxLinks:
+				result.UncheckedDueToCap++
+			}
+		}
+		if o.status != linkUnchecked && o.status != linkNonHTTP {
+			result.LinksChecked++
 		}
+
+		if includeDetails {
+			result.Links = append(result.Links, model.LinkReport{
+				URL:             o.link.url,
+				Href:            o.link.href,
+				Text:            o.link.text,
+				Internal:        o.isInternal,
+				Occurrences:     o.link.occurrences,
+				Rel:             strings.Join(o.link.rel, " "),
+				Target:          o.link.target,
+				Status:          o.status.String(),
+				StatusCode:      o.statusCode,
+				Method:          o.method,
+				ErrorCategory:   o.errorCat,
+				LatencyMs:       o.latency.Milliseconds(),
+				FinalURL:        o.finalURL,
+				Cached:          o.cached,
+				Soft404:         o.soft404,
+				UncheckedReason: o.uncheckedReason,
+			})
+		}
+	}
+
+	result.Partial = result.UncheckedDueToTimeout > 0 || result.UncheckedDueToCap > 0 || result.RateLimitedLinks > 0
+}
+
+// linkCheckRun is the state shared by every request the link-checking stage of one analysis makes. Links,
+// fragments and resources are all checked under the same budget (the ctx newLinkCheckRun returns), wait on the same
+// host throttle and take their slots from the same scheduler share.
+type linkCheckRun struct {
+	site     *sameSite
+	share    *schedulerShare
+	throttle *hostThrottle
+	// probes is only set when soft-404 detection is enabled
+	probes *soft404Probes
+	// userAgent is what every request of the run identifies itself as, empty for the default
+	userAgent string
+}
+
+// newLinkCheckRun starts the link-checking stage of an analysis, its ctx is bounded by opts.LinkCheckTimeout. The
+// returned func ends the stage and must be called once every check is done.
+func (a *analyzer) newLinkCheckRun(ctx context.Context, site *sameSite, opts AnalyzeOptions) (context.Context, *linkCheckRun, func()) {
+	ctx, cancel := context.WithTimeout(ctx, opts.LinkCheckTimeout)
+	run := &linkCheckRun{
+		site: site,
+		// Limit the number of concurrent requests to avoid overwhelming the server, the scheduler also keeps all
+		// analyses together under the process-wide ceiling
+		share:     a.scheduler.join(opts.LinkCheckConcurrency),
+		throttle:  newHostThrottle(opts.PerHostConcurrency),
+		userAgent: opts.userAgent(),
+	}
+	if opts.DetectSoft404 {
+		run.probes = newSoft404Probes()
+	}
+	return ctx, run, func() {
+		cancel()
+		run.share.leave()
 	}
 }
 
-func (a *analyzer) checkSingleLink(ctx context.Context, link string, baseURL *url.URL) (isInternal bool, isAccessible bool) {
-	linkURL, err := url.Parse(link)
+// politely runs fn once the host lets it: a free host slot, the delay since the previous request to the host and a
+// scheduler slot. It gives up with ctx's error when the budget runs out first.
+func (r *linkCheckRun) politely(ctx context.Context, host string, delay time.Duration, fn func()) error {
+	releaseHost, err := r.throttle.acquire(ctx, host)
 	if err != nil {
-		return false, false
+		return err
 	}
+	defer releaseHost()
 
-	isInternal = linkURL.Host == "" || linkURL.Host == baseURL.Host
+	if err := r.throttle.wait(ctx, host, delay); err != nil {
+		return err
+	}
+	release, err := r.share.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	fn()
+	return nil
+}
 
+// hostDelay applies robots.txt to u when asked to: it reports whether u may be requested, and how far apart
+// requests to its host must be. It fails with the transport error robots.txt could not be fetched with.
+func (a *analyzer) hostDelay(ctx context.Context, u *url.URL, opts AnalyzeOptions) (delay time.Duration, allowed bool, err error) {
+	if !opts.RespectRobots {
+		return opts.HostDelay, true, nil
+	}
+	rules, err := a.robots.rulesFor(ctx, u, opts.userAgent())
 	if err != nil {
-		return isInternal, false
+		return 0, false, err
 	}
+	if !rules.allowed(opts.RobotsUserAgent, u) {
+		return 0, false, nil
+	}
+	return max(opts.HostDelay, rules.crawlDelay(opts.RobotsUserAgent)), true, nil
+}
 
-	resp, err := a.client.Do(req)
+// checkLinksConcurrently checks the sampled links and returns their outcomes in document order, followed by the
+// links left out of the sample.
+func (a *analyzer) checkLinksConcurrently(ctx context.Context, sample linkSample, run *linkCheckRun, opts AnalyzeOptions) []linkOutcome {
+	// links left out of the sample are still classified, they just aren't requested
+	links := sample.selected
+	unchecked := classifyLinks(sample.rest, run.site, uncheckedMaxLinks)
+
+	var wg sync.WaitGroup
+
+	// each goroutine owns one slot, so no locking is needed
+	checked := make([]linkOutcome, len(links))
+
+	progress := newLinkProgress(opts.Progress, len(links))
+
+	for i, link := range links {
+		wg.Add(1)
+		go func(i int, link linkRef) {
+			defer wg.Done()
+			checked[i] = a.checkLink(ctx, link, run, opts)
+			progress.add(checked[i])
+		}(i, link)
+	}
+
+	wg.Wait()
+
+	return append(checked, unchecked...)
+}
+
+// checkLink applies robots.txt, answers from the link cache when it can and otherwise checks the link.
+func (a *analyzer) checkLink(ctx context.Context, link linkRef, run *linkCheckRun, opts AnalyzeOptions) linkOutcome {
+	outcome := linkOutcome{link: link, status: linkInaccessible}
+
+	linkURL, err := url.Parse(link.url)
+	if err != nil {
+		outcome.errorCat = linkErrorInvalid
+		return outcome
+	}
+	outcome.isInternal = run.site.isInternal(linkURL)
+
+	delay, allowed, err := a.hostDelay(ctx, linkURL, opts)
+	// robots.txt that could not be fetched in time says nothing about the link
+	if ctx.Err() != nil {
+		return outOfTime(outcome)
+	}
+	if err != nil {
+		// the host could not even be asked for robots.txt, the link would fail the same way
+		outcome.errorCat = linkErrorCategory(err)
+		return outcome
+	}
+	if !allowed {
+		outcome.status = linkSkippedRobots
+		return outcome
+	}
+
+	if !opts.BypassLinkCache {
+		if cached, ok := a.links.get(linkCacheVariant(opts), link.url); ok {
+			// the verdict comes from the cache, how the link was found comes from this document
+			cached.link, cached.isInternal, cached.cached = link, outcome.isInternal, true
+			return cached
+		}
+	}
+
+	outcome = a.checkLinkWithBackoff(ctx, outcome, linkURL, run, delay, opts)
+	// a check cut short by our own deadline says nothing about the link
+	if ctx.Err() == nil {
+		a.links.put(linkCacheVariant(opts), outcome)
+	}
+	return outcome
+}
+
+// checkLinkWithBackoff checks the link once the host and a global concurrency slot are free, backing off and
+// retrying while the host rate limits us.
+func (a *analyzer) checkLinkWithBackoff(ctx context.Context, outcome linkOutcome, linkURL *url.URL, run *linkCheckRun, delay time.Duration, opts AnalyzeOptions) linkOutcome {
+	releaseHost, err := run.throttle.acquire(ctx, linkURL.Host)
+	if err != nil {
+		return outOfTime(outcome)
+	}
+	defer releaseHost()
+
+	for attempt := 0; ; attempt++ {
+		// wait before taking a global slot, so slow hosts do not hold up checks of other hosts
+		if err := run.throttle.wait(ctx, linkURL.Host, delay); err != nil {
+			return outOfTime(outcome)
+		}
+
+		// stop queueing new checks as soon as the caller goes away
+		release, err := run.share.acquire(ctx)
+		if err != nil {
+			return outOfTime(outcome)
+		}
+		// start over, nothing about a rate limited attempt should leak into the next verdict
+		outcome = linkOutcome{link: outcome.link, isInternal: outcome.isInternal, status: linkInaccessible}
+		a.checkSingleLink(ctx, &outcome, opts.LinkCheckMethod, run.userAgent)
+		release()
+		if run.probes != nil && outcome.status == linkAccessible {
+			a.flagSoft404(ctx, &outcome, linkURL, run, delay)
+		}
+
+		if !isRateLimited(&outcome) {
+			return outcome
+		}
+		if attempt >= *opts.RateLimitRetries || ctx.Err() != nil {
+			outcome.status = linkRateLimited
+			outcome.errorCat = ""
+			return outcome
+		}
+
+		backoff := outcome.retryAfter
+		if backoff == 0 {
+			backoff = constants.RateLimitBaseBackoff << attempt
+		}
+		run.throttle.backoff(linkURL.Host, min(backoff, constants.MaxRetryAfter))
+	}
+}
+
+// outOfTime marks a link that the link-check budget ran out on as unchecked rather than inaccessible.
+func outOfTime(outcome linkOutcome) linkOutcome {
+	outcome.status = linkUnchecked
+	outcome.uncheckedReason = uncheckedTimeout
+	outcome.statusCode, outcome.errorCat, outcome.method = 0, "", ""
+	return outcome
+}
+
+// isRateLimited reports whether the response asks us to slow down: any 429, or a 503 with Retry-After.
+func isRateLimited(outcome *linkOutcome) bool {
+	return outcome.statusCode == http.StatusTooManyRequests ||
+		outcome.statusCode == http.StatusServiceUnavailable && outcome.retryAfter > 0
+}
 
-	if err != nil || resp.StatusCode >= 400 {
-		return isInternal, false
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// checkSingleLink requests the link with the configured method(s) and records the verdict in outcome.
+func (a *analyzer) checkSingleLink(ctx context.Context, outcome *linkOutcome, method LinkCheckMethod, userAgent string) {
+	if method == LinkCheckGet {
+		a.requestLink(ctx, outcome, http.MethodGet, userAgent)
+		return
+	}
+
+	a.requestLink(ctx, outcome, http.MethodHead, userAgent)
+	if method == LinkCheckHeadThenGet && headRejected(outcome) && ctx.Err() == nil {
+		// start over, nothing about the HEAD attempt should leak into the GET verdict
+		retry := linkOutcome{link: outcome.link, isInternal: outcome.isInternal, status: linkInaccessible}
+		a.requestLink(ctx, &retry, http.MethodGet, userAgent)
+		retry.latency += outcome.latency
+		*outcome = retry
+	}
+}
+
+// headRejected reports whether a HEAD verdict looks like the server refusing the method rather than the
+// resource being broken, so that it is worth asking again with GET.
+func headRejected(outcome *linkOutcome) bool {
+	if outcome.status == linkAccessible {
+		return false
+	}
+	switch outcome.statusCode {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusMethodNotAllowed,
+		http.StatusNotAcceptable, http.StatusNotImplemented:
+		return true
+	}
+	// some servers just drop the connection on HEAD, a refused connection never got as far as the method though
+	return outcome.errorCat == linkErrorConnection
+}
+
+func (a *analyzer) requestLink(ctx context.Context, outcome *linkOutcome, method string, userAgent string) {
+	outcome.method = method
+
+	req, err := http.NewRequestWithContext(ctx, method, outcome.link.url, nil)
+	if err != nil {
+		outcome.errorCat = linkErrorInvalid
+		return
+	}
+	identify(req, userAgent)
+	if method == http.MethodGet {
+		// one byte is enough to prove the resource is there
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	start := time.Now()
+	resp, err := a.client.Do(req)
+	outcome.latency = time.Since(start)
+	if err != nil {
+		if ctx.Err() != nil {
+			// our budget ran out, not the link's patience
+			latency := outcome.latency
+			*outcome = outOfTime(*outcome)
+			outcome.latency = latency
+			return
+		}
+		outcome.errorCat = linkErrorCategory(err)
+		return
 	}
 
 	defer func(Body io.ReadCloser) {
+		// drain what little is left so the connection can be reused, servers that ignore Range get cut off
+		_, _ = io.Copy(io.Discard, io.LimitReader(Body, constants.LinkCheckMaxDrainBytes))
 		err := Body.Close()
 		if err != nil {
 			slog.Error("failed to close response body", "error", err)
 		}
 	}(resp.Body)
 
-	return isInternal, true
+	outcome.statusCode = resp.StatusCode
+	outcome.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	if final := resp.Request.URL.String(); final != outcome.link.url {
+		outcome.finalURL = final
+	}
+
+	switch {
+	case method == http.MethodGet && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// an empty resource cannot satisfy bytes=0-0, but it does exist
+		outcome.status = linkAccessible
+	case resp.StatusCode >= 500:
+		outcome.errorCat = linkErrorServerError
+	case resp.StatusCode >= 400:
+		outcome.errorCat = linkErrorClientError
+	default:
+		outcome.status = linkAccessible
+	}
+}
+
+// linkErrorCategory condenses a transport error into one of the link error categories.
+func linkErrorCategory(err error) string {
+	switch classifyRequestError(err).Code {
+	case CodeDNSFailure:
+		return linkErrorDNS
+	case CodeTLSFailure:
+		return linkErrorTLS
+	case CodeTimeout:
+		return linkErrorTimeout
+	case CodeBlockedTarget:
+		return linkErrorBlocked
+	case CodeRedirectLoop, CodeTooManyRedirects:
+		return linkErrorRedirects
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return linkErrorRefused
+	}
+	return linkErrorConnection
 }
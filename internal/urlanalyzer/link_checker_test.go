@@ -0,0 +1,460 @@
+package urlanalyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAnalyzePage_LinkDetails(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<html><body>
+			<a href="/ok">Fine <b>link</b></a>
+			<a href="missing">Gone</a>
+			<a href="/broken">Broken</a>
+			<a href="/old">Moved</a>
+		</body></html>`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", AnalyzeOptions{IncludeLinkDetails: true})
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	want := []model.LinkReport{
+		{URL: ts.URL + "/ok", Href: "/ok", Text: "Fine link", Internal: true, Occurrences: 1, Status: "accessible", StatusCode: 200, Method: "HEAD"},
+		{URL: ts.URL + "/missing", Href: "missing", Text: "Gone", Internal: true, Occurrences: 1, Status: "inaccessible", StatusCode: 404, Method: "HEAD", ErrorCategory: "4xx"},
+		{URL: ts.URL + "/broken", Href: "/broken", Text: "Broken", Internal: true, Occurrences: 1, Status: "inaccessible", StatusCode: 502, Method: "HEAD", ErrorCategory: "5xx"},
+		{URL: ts.URL + "/old", Href: "/old", Text: "Moved", Internal: true, Occurrences: 1, Status: "accessible", StatusCode: 200, Method: "HEAD", FinalURL: ts.URL + "/ok"},
+	}
+
+	if len(result.Links) != len(want) {
+		t.Fatalf("expected %d link reports, got %d", len(want), len(result.Links))
+	}
+	for i, got := range result.Links {
+		got.LatencyMs = 0
+		if got != want[i] {
+			t.Errorf("link %d: expected %+v, got %+v", i, want[i], got)
+		}
+	}
+
+	result, err = service.AnalyzePage(context.Background(), ts.URL+"/page", DefaultAnalyzeOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+	if result.Links != nil {
+		t.Errorf("expected no per-link report unless requested, got %d entries", len(result.Links))
+	}
+}
+
+func TestAnalyzePage_HeadToGetFallback(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Range") != "bytes=0-0" {
+			t.Errorf("expected a ranged GET, got Range %q", r.Header.Get("Range"))
+		}
+		w.WriteHeader(http.StatusPartialContent)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<html><body><a href="/no-head">No HEAD</a><a href="/gone">Gone</a></body></html>`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	tests := []struct {
+		method           LinkCheckMethod
+		wantInaccessible int
+		wantMethods      []string
+	}{
+		{method: LinkCheckHeadThenGet, wantInaccessible: 1, wantMethods: []string{"GET", "HEAD"}},
+		{method: LinkCheckHead, wantInaccessible: 2, wantMethods: []string{"HEAD", "HEAD"}},
+		{method: LinkCheckGet, wantInaccessible: 1, wantMethods: []string{"GET", "GET"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.method), func(t *testing.T) {
+			opts := AnalyzeOptions{IncludeLinkDetails: true, LinkCheckMethod: tc.method}
+			result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts)
+			if err != nil {
+				t.Fatalf("AnalyzePage failed: %v", err)
+			}
+
+			if result.InaccessibleInternalLinks != tc.wantInaccessible {
+				t.Errorf("expected %d inaccessible links, got %d", tc.wantInaccessible, result.InaccessibleInternalLinks)
+			}
+			for i, link := range result.Links {
+				if link.Method != tc.wantMethods[i] {
+					t.Errorf("expected %s to be decided by %s, got %s", link.URL, tc.wantMethods[i], link.Method)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzePage_RateLimitedLinks(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	inFlight, maxInFlight := 0, 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		n := calls[r.URL.Path]
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		leave := sync.OnceFunc(func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		})
+		defer leave()
+		time.Sleep(5 * time.Millisecond)
+
+		switch r.URL.Path {
+		case "/page":
+			_, _ = fmt.Fprint(w, `<html><body>
+				<a href="/a">A</a><a href="/b">B</a><a href="/c">C</a>
+				<a href="/busy-once">Busy once</a>
+				<a href="/always-limited">Always limited</a>
+				<a href="/down">Down</a>
+				<a href="/busy-then-gone">Busy then gone</a>
+			</body></html>`)
+		case "/busy-once":
+			if n == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "/always-limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		case "/busy-then-gone":
+			if n == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			// the retry fails without any response at all; the client sees that before the handler returns, so
+			// the request has to leave the in-flight count first
+			leave()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		case "/down":
+			// no Retry-After, so this is an outage rather than rate limiting
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	oneRetry := 1
+	opts := AnalyzeOptions{PerHostConcurrency: 1, RateLimitRetries: &oneRetry, LinkCheckMethod: LinkCheckHead, IncludeLinkDetails: true}
+	result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts)
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	// the handler of a hijacked connection may still be returning
+	mu.Lock()
+	defer mu.Unlock()
+
+	if result.RateLimitedLinks != 1 {
+		t.Errorf("expected 1 rate limited link, got %d", result.RateLimitedLinks)
+	}
+	if result.InaccessibleInternalLinks != 2 {
+		t.Errorf("expected /down and /busy-then-gone to be inaccessible, got %d", result.InaccessibleInternalLinks)
+	}
+	if calls["/busy-once"] != 2 || calls["/always-limited"] != 2 {
+		t.Errorf("expected one retry per rate limited link, got %v", calls)
+	}
+	if calls["/down"] != 1 {
+		t.Errorf("expected a 503 without Retry-After not to be retried, got %d calls", calls["/down"])
+	}
+	// the page fetch itself runs before any link check, so only link checks can overlap
+	if maxInFlight != 1 {
+		t.Errorf("expected at most 1 request in flight per host, got %d", maxInFlight)
+	}
+
+	statuses := map[string]string{}
+	for _, link := range result.Links {
+		statuses[link.Href] = link.Status
+		switch link.Href {
+		case "/busy-once":
+			if link.ErrorCategory != "" || link.StatusCode != http.StatusOK {
+				t.Errorf("expected the 429 before the 200 to be forgotten, got %+v", link)
+			}
+		case "/busy-then-gone":
+			if link.ErrorCategory == "" || link.StatusCode != 0 {
+				t.Errorf("expected the failed retry to decide the verdict, got %+v", link)
+			}
+		}
+	}
+	if statuses["/busy-once"] != "accessible" || statuses["/always-limited"] != "rate_limited" ||
+		statuses["/busy-then-gone"] != "inaccessible" {
+		t.Errorf("unexpected link statuses %v", statuses)
+	}
+}
+
+func TestAnalyzePage_NoRateLimitRetries(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			_, _ = fmt.Fprint(w, `<html><body><a href="/limited">Limited</a></body></html>`)
+			return
+		}
+		calls.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	noRetries := 0
+	opts := AnalyzeOptions{RateLimitRetries: &noRetries, LinkCheckMethod: LinkCheckHead}
+	result, err := NewAnalyzer(httpClient).AnalyzePage(context.Background(), ts.URL+"/page", opts)
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+	if calls.Load() != 1 || result.RateLimitedLinks != 1 {
+		t.Errorf("expected an explicit rateLimitRetries of 0 to never retry, got %d calls and %+v", calls.Load(), result)
+	}
+}
+
+func TestAnalyzePage_HostDelay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			_, _ = fmt.Fprint(w, `<html><body><a href="/a">A</a><a href="/b">B</a><a href="/c">C</a></body></html>`)
+		}
+	}))
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	start := time.Now()
+	_, err := service.AnalyzePage(context.Background(), ts.URL+"/page", AnalyzeOptions{HostDelay: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+	// three checks against one host need at least two delays between them
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected link checks to be spaced out by the host delay, took only %s", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got <= 0 || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %s, want up to a minute", future, got)
+	}
+}
+
+func TestAnalyzePage_NonHTTPLinks(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			requests++
+			return
+		}
+		_, _ = fmt.Fprint(w, `<html><body>
+			<a href="/ok">Fine</a>
+			<a href="mailto:info@example.com">Mail</a>
+			<a href="MAILTO:sales@example.com">Sales</a>
+			<a href="tel:+49301234567">Call</a>
+			<a href="javascript:void(0)">Menu</a>
+			<a href="data:text/plain,hello">Data</a>
+			<a href="ftp://ftp.example.com/file.zip">Download</a>
+			<a href="sms:+49301234567">Text</a>
+		</body></html>`)
+	}))
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", AnalyzeOptions{IncludeLinkDetails: true})
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	want := model.NonHTTPLinks{Mailto: 2, Tel: 1, JavaScript: 1, Data: 1, FTP: 1, Other: 1}
+	if result.NonHTTPLinks != want {
+		t.Errorf("expected %+v, got %+v", want, result.NonHTTPLinks)
+	}
+	if result.InternalLinks != 1 || result.ExternalLinks != 0 || result.LinksChecked != 1 {
+		t.Errorf("expected only the HTTP link to be counted and checked, got %+v", result)
+	}
+	if result.InaccessibleInternalLinks != 0 || result.InaccessibleExternalLinks != 0 {
+		t.Errorf("expected no inaccessible links, got %+v", result)
+	}
+	if requests != 1 {
+		t.Errorf("expected a single link check request, got %d", requests)
+	}
+	if len(result.JavaScriptLinks) != 1 || result.JavaScriptLinks[0] != "javascript:void(0)" {
+		t.Errorf("expected the javascript: link to be flagged, got %v", result.JavaScriptLinks)
+	}
+	if got := result.Links[1]; got.Status != "non_http" || got.Internal {
+		t.Errorf("expected mailto link to be reported as non_http and not internal, got %+v", got)
+	}
+}
+
+func TestAnalyzePage_UncheckedLinks(t *testing.T) {
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<html><body>
+			<a href="/fast">Fast</a>
+			<a href="/missing">Missing</a>
+			<a href="/slow">Slow</a>
+			<a href="/slow?again">Slow again</a>
+			<a href="/capped">Capped</a>
+		</body></html>`)
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	defer close(release)
+
+	service := NewAnalyzer(httpClient)
+
+	opts := AnalyzeOptions{MaxLinks: 4, LinkCheckTimeout: 200 * time.Millisecond, LinkCheckMethod: LinkCheckHead, IncludeLinkDetails: true}
+	result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts)
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	if result.InaccessibleInternalLinks != 1 {
+		t.Errorf("expected only /missing to be inaccessible, got %d", result.InaccessibleInternalLinks)
+	}
+	if result.UncheckedLinks != 3 || result.UncheckedDueToTimeout != 2 || result.UncheckedDueToCap != 1 {
+		t.Errorf("expected 2 links unchecked by timeout and 1 by the cap, got %+v", result)
+	}
+	if !result.Partial {
+		t.Error("expected the result to be marked partial")
+	}
+
+	want := map[string]string{"/fast": "accessible", "/missing": "inaccessible", "/slow": "unchecked", "/slow?again": "unchecked", "/capped": "unchecked"}
+	for _, link := range result.Links {
+		if link.Status != want[link.Href] {
+			t.Errorf("%s: expected %s, got %s", link.Href, want[link.Href], link.Status)
+		}
+		if link.Status == "unchecked" && link.ErrorCategory != "" {
+			t.Errorf("%s: expected no error category on an unchecked link, got %q", link.Href, link.ErrorCategory)
+		}
+	}
+
+	result, err = service.AnalyzePage(context.Background(), ts.URL+"/page", AnalyzeOptions{SkipLinkCheck: true})
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+	if result.UncheckedLinks != 5 || result.Partial {
+		t.Errorf("expected deliberately skipped link checks not to be partial, got %+v", result)
+	}
+}
+
+func TestLinkErrorCategory(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "refused", err: &url.Error{Op: "Head", URL: "http://example.com", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, want: "refused"},
+		{name: "reset", err: &url.Error{Op: "Head", URL: "http://example.com", Err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, want: "connection"},
+		{name: "eof", err: &url.Error{Op: "Head", URL: "http://example.com", Err: io.EOF}, want: "connection"},
+		{name: "malformed response", err: &url.Error{Op: "Get", URL: "http://example.com", Err: errors.New(`malformed HTTP response "\x00"`)}, want: "connection"},
+		{name: "dns", err: &url.Error{Op: "Get", URL: "http://example.invalid", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}, want: "dns"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := linkErrorCategory(tc.err); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAnalyzePage_RefusedLinkIsNotRetriedWithGet(t *testing.T) {
+	// grab a free port and close it again, so that connecting to it is refused
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	refusedURL := closed.URL + "/gone"
+	closed.Close()
+
+	ts := startTestServer(`<html><body><a href="` + refusedURL + `">Gone</a></body></html>`)
+	defer ts.Close()
+
+	result, err := NewAnalyzer(httpClient).AnalyzePage(context.Background(), ts.URL, AnalyzeOptions{IncludeLinkDetails: true})
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+	if len(result.Links) != 1 || result.Links[0].ErrorCategory != "refused" || result.Links[0].Method != http.MethodHead {
+		t.Errorf("expected a refused HEAD without a GET retry, got %+v", result.Links)
+	}
+}
@@ -0,0 +1,89 @@
+package urlanalyzer
+
+import (
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"math/rand/v2"
+	"slices"
+)
+
+// linkSample splits the links of a page into the ones to check and the ones left unchecked, both in document order.
+type linkSample struct {
+	selected []linkRef
+	rest     []linkRef
+	strategy LinkSampling
+	seed     int64
+	max      int
+}
+
+// sampleLinks picks at most opts.MaxLinks links with the opts.LinkSampling strategy. The choice only depends on
+// the links and the options, so analyzing the same page twice checks the same links.
+func sampleLinks(links []linkRef, site *sameSite, opts AnalyzeOptions) linkSample {
+	sample := linkSample{strategy: opts.LinkSampling, max: opts.MaxLinks}
+	if opts.LinkSampling == SampleRandom {
+		sample.seed = opts.SampleSeed
+	}
+	if opts.MaxLinks <= 0 || len(links) <= opts.MaxLinks {
+		sample.selected = links
+		return sample
+	}
+
+	var picked []int
+	switch opts.LinkSampling {
+	case SampleStratified:
+		picked = stratifiedSample(links, site, opts.MaxLinks)
+	case SampleRandom:
+		rng := rand.New(rand.NewPCG(uint64(opts.SampleSeed), 0))
+		picked = rng.Perm(len(links))[:opts.MaxLinks]
+	default:
+		for i := range opts.MaxLinks {
+			picked = append(picked, i)
+		}
+	}
+
+	keep := make([]bool, len(links))
+	for _, i := range picked {
+		keep[i] = true
+	}
+	for i, link := range links {
+		if keep[i] {
+			sample.selected = append(sample.selected, link)
+		} else {
+			sample.rest = append(sample.rest, link)
+		}
+	}
+	return sample
+}
+
+// stratifiedSample splits n between internal and external links in proportion to how many of each there are,
+// keeping at least one of each, and takes the first ones of each kind.
+func stratifiedSample(links []linkRef, site *sameSite, n int) []int {
+	var internal, external []int
+	for i, link := range links {
+		if site.isInternalLink(link.url) {
+			internal = append(internal, i)
+		} else {
+			external = append(external, i)
+		}
+	}
+
+	internalQuota := (n*len(internal) + len(links)/2) / len(links)
+	if len(internal) > 0 && len(external) > 0 {
+		internalQuota = max(1, min(internalQuota, n-1))
+	}
+	externalQuota := min(n-internalQuota, len(external))
+	// whatever one kind cannot use goes to the other
+	internalQuota = min(n-externalQuota, len(internal))
+
+	return slices.Concat(internal[:internalQuota], external[:externalQuota])
+}
+
+// report describes the sample for the result.
+func (s linkSample) report() model.LinkSample {
+	return model.LinkSample{
+		Strategy:   string(s.strategy),
+		Seed:       s.seed,
+		MaxLinks:   s.max,
+		Discovered: len(s.selected) + len(s.rest),
+		Sampled:    len(s.selected),
+	}
+}
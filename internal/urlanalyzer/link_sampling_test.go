@@ -0,0 +1,94 @@
+package urlanalyzer
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestSampleLinks(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/")
+	site := newSameSite(pageURL, AnalyzeOptions{SameSitePolicy: SameSiteExactHost})
+
+	// 8 internal links followed by 2 external ones
+	var links []linkRef
+	for i := range 8 {
+		links = append(links, linkRef{url: fmt.Sprintf("https://example.com/%d", i)})
+	}
+	links = append(links, linkRef{url: "https://other.example/a"}, linkRef{url: "https://other.example/b"})
+
+	urls := func(refs []linkRef) []string {
+		var out []string
+		for _, r := range refs {
+			out = append(out, r.url)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name string
+		opts AnalyzeOptions
+		want []string
+	}{
+		{
+			name: "under the cap",
+			opts: AnalyzeOptions{MaxLinks: 10, LinkSampling: SampleFirst},
+			want: urls(links),
+		},
+		{
+			name: "first",
+			opts: AnalyzeOptions{MaxLinks: 3, LinkSampling: SampleFirst},
+			want: []string{"https://example.com/0", "https://example.com/1", "https://example.com/2"},
+		},
+		{
+			name: "stratified keeps an external link",
+			opts: AnalyzeOptions{MaxLinks: 3, LinkSampling: SampleStratified},
+			want: []string{"https://example.com/0", "https://example.com/1", "https://other.example/a"},
+		},
+		{
+			name: "stratified in proportion",
+			opts: AnalyzeOptions{MaxLinks: 5, LinkSampling: SampleStratified},
+			want: []string{"https://example.com/0", "https://example.com/1", "https://example.com/2",
+				"https://example.com/3", "https://other.example/a"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sample := sampleLinks(links, site, tc.opts)
+			if got := urls(sample.selected); !slices.Equal(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+			if len(sample.selected)+len(sample.rest) != len(links) {
+				t.Errorf("expected every link to be either selected or left out, got %d + %d", len(sample.selected), len(sample.rest))
+			}
+		})
+	}
+
+	t.Run("random is reproducible", func(t *testing.T) {
+		opts := AnalyzeOptions{MaxLinks: 4, LinkSampling: SampleRandom, SampleSeed: 42}
+		first, second := sampleLinks(links, site, opts), sampleLinks(links, site, opts)
+		if !slices.Equal(urls(first.selected), urls(second.selected)) {
+			t.Errorf("expected the same seed to pick the same links, got %v and %v", urls(first.selected), urls(second.selected))
+		}
+		if len(first.selected) != 4 {
+			t.Errorf("expected 4 links, got %d", len(first.selected))
+		}
+
+		report := first.report()
+		if report.Seed != 42 || report.Discovered != 10 || report.Sampled != 4 || report.Strategy != "random" {
+			t.Errorf("unexpected report %+v", report)
+		}
+	})
+
+	t.Run("random picks a seed", func(t *testing.T) {
+		opts := AnalyzeOptions{MaxLinks: 4, LinkSampling: SampleRandom}.withDefaults()
+		if opts.SampleSeed == 0 {
+			t.Fatal("expected a seed to be picked")
+		}
+		if report := sampleLinks(links, site, opts).report(); report.Seed != opts.SampleSeed {
+			t.Errorf("expected the picked seed %d to be reported, got %d", opts.SampleSeed, report.Seed)
+		}
+	})
+}
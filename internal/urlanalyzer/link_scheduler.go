@@ -0,0 +1,147 @@
+package urlanalyzer
+
+import (
+	"context"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"sync"
+)
+
+// linkScheduler hands out the outbound request slots of the whole process. Every analysis checking links joins
+// with its own concurrency limit, and whenever a slot frees up it goes to the waiting analysis with the fewest
+// requests in flight, so a page with thousands of links cannot starve the analyses that started after it.
+type linkScheduler struct {
+	capacity int
+
+	mu       sync.Mutex
+	inFlight int
+	queued   int
+	// shares are the analyses currently checking links, in the order they joined
+	shares []*schedulerShare
+}
+
+// schedulerShare is one analysis' claim on the scheduler.
+type schedulerShare struct {
+	scheduler *linkScheduler
+	limit     int
+	inFlight  int
+	// waiters are granted a slot by receiving on their channel, first come first served
+	waiters []chan struct{}
+}
+
+func newLinkScheduler(capacity int) *linkScheduler {
+	return &linkScheduler{capacity: capacity}
+}
+
+// join registers an analysis that may run up to limit requests at once. Call leave once it is done.
+func (s *linkScheduler) join(limit int) *schedulerShare {
+	share := &schedulerShare{scheduler: s, limit: limit}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shares = append(s.shares, share)
+	return share
+}
+
+// leave unregisters the analysis. Its requests must all have been released.
+func (sh *schedulerShare) leave() {
+	s := sh.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, share := range s.shares {
+		if share == sh {
+			s.shares = append(s.shares[:i], s.shares[i+1:]...)
+			break
+		}
+	}
+}
+
+// acquire blocks until the analysis may start a request or ctx is done. The returned func gives the slot back.
+func (sh *schedulerShare) acquire(ctx context.Context) (release func(), err error) {
+	s := sh.scheduler
+	s.mu.Lock()
+	// nobody can be waiting on a free global slot, so taking it is fair as long as this share is not queued itself
+	if s.inFlight < s.capacity && sh.inFlight < sh.limit && len(sh.waiters) == 0 {
+		s.grant(sh)
+		s.mu.Unlock()
+		return sh.release, nil
+	}
+	granted := make(chan struct{}, 1)
+	sh.waiters = append(sh.waiters, granted)
+	s.queued++
+	s.mu.Unlock()
+
+	select {
+	case <-granted:
+		return sh.release, nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	for i, w := range sh.waiters {
+		if w == granted {
+			sh.waiters = append(sh.waiters[:i], sh.waiters[i+1:]...)
+			s.queued--
+			s.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	s.mu.Unlock()
+	// the slot was granted while ctx was finishing, pass it on
+	sh.release()
+	return nil, ctx.Err()
+}
+
+func (sh *schedulerShare) release() {
+	s := sh.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh.inFlight--
+	s.inFlight--
+	s.dispatch()
+}
+
+// dispatch hands free slots to waiting analyses, fewest requests in flight first. Callers hold s.mu.
+func (s *linkScheduler) dispatch() {
+	for s.inFlight < s.capacity {
+		var next *schedulerShare
+		for _, share := range s.shares {
+			if len(share.waiters) == 0 || share.inFlight >= share.limit {
+				continue
+			}
+			if next == nil || share.inFlight < next.inFlight {
+				next = share
+			}
+		}
+		if next == nil {
+			return
+		}
+
+		granted := next.waiters[0]
+		next.waiters = next.waiters[1:]
+		s.queued--
+		s.grant(next)
+		granted <- struct{}{}
+	}
+}
+
+// grant takes a slot for share. Callers hold s.mu.
+func (s *linkScheduler) grant(share *schedulerShare) {
+	share.inFlight++
+	s.inFlight++
+}
+
+// stats snapshots the scheduler for monitoring.
+func (s *linkScheduler) stats() model.LinkCheckStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return model.LinkCheckStats{
+		Capacity:       s.capacity,
+		InFlight:       s.inFlight,
+		Queued:         s.queued,
+		ActiveAnalyses: len(s.shares),
+		Utilization:    float64(s.inFlight) / float64(s.capacity),
+	}
+}
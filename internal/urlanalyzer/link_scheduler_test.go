@@ -0,0 +1,124 @@
+package urlanalyzer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLinkScheduler_GlobalCeiling(t *testing.T) {
+	s := newLinkScheduler(2)
+	first, second := s.join(2), s.join(2)
+	defer first.leave()
+	defer second.leave()
+
+	releaseA, _ := first.acquire(context.Background())
+	releaseB, _ := second.acquire(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := first.acquire(ctx); err == nil {
+		t.Fatal("expected the ceiling to hold back a third request")
+	}
+
+	stats := s.stats()
+	if stats.InFlight != 2 || stats.Queued != 0 || stats.ActiveAnalyses != 2 || stats.Utilization != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	releaseA()
+	releaseB()
+	if stats := s.stats(); stats.InFlight != 0 || stats.Utilization != 0 {
+		t.Errorf("expected every slot back, got %+v", stats)
+	}
+}
+
+func TestLinkScheduler_PerAnalysisLimit(t *testing.T) {
+	s := newLinkScheduler(10)
+	share := s.join(1)
+	defer share.leave()
+
+	release, _ := share.acquire(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := share.acquire(ctx); err == nil {
+		t.Fatal("expected the analysis' own limit to hold back a second request")
+	}
+	release()
+}
+
+func TestLinkScheduler_FairShare(t *testing.T) {
+	s := newLinkScheduler(2)
+	busy, late := s.join(10), s.join(10)
+	defer busy.leave()
+	defer late.leave()
+
+	// the busy analysis holds every slot and has more checks queued
+	release1, _ := busy.acquire(context.Background())
+	release2, _ := busy.acquire(context.Background())
+
+	got := make(chan string, 2)
+	queue := func(name string, share *schedulerShare) {
+		release, err := share.acquire(context.Background())
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			return
+		}
+		got <- name
+		release()
+	}
+	go queue("busy", busy)
+	waitFor(t, func() bool { return s.stats().Queued == 1 })
+	go queue("late", late)
+	waitFor(t, func() bool { return s.stats().Queued == 2 })
+
+	// the freed slot goes to the analysis with nothing in flight, although the busy one queued first
+	release1()
+	if first := <-got; first != "late" {
+		t.Errorf("expected the late analysis to get the first free slot, got %s", first)
+	}
+	release2()
+	<-got
+
+	if stats := s.stats(); stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("expected an idle scheduler, got %+v", stats)
+	}
+}
+
+func TestLinkScheduler_CanceledWaiter(t *testing.T) {
+	s := newLinkScheduler(1)
+	share := s.join(1)
+	defer share.leave()
+
+	release, _ := share.acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := share.acquire(ctx)
+		done <- err
+	}()
+	waitFor(t, func() bool { return s.stats().Queued == 1 })
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("expected the canceled waiter to give up")
+	}
+
+	release()
+	if stats := s.stats(); stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("expected the canceled waiter to leave no trace, got %+v", stats)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,59 @@
+package urlanalyzer
+
+import (
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"net/url"
+)
+
+// splitByScheme separates the links that can be checked over HTTP from mailto:, tel:, javascript: and other
+// links that cannot, keeping document order in both.
+func splitByScheme(links []linkRef) (httpLinks, nonHTTP []linkRef) {
+	for _, link := range links {
+		if isHTTPLink(link.url) {
+			httpLinks = append(httpLinks, link)
+		} else {
+			nonHTTP = append(nonHTTP, link)
+		}
+	}
+	return httpLinks, nonHTTP
+}
+
+func isHTTPLink(link string) bool {
+	u, err := url.Parse(link)
+	// unparsable links are left to the checker, which reports them as invalid_url
+	return err != nil || u.Scheme == "http" || u.Scheme == "https"
+}
+
+// nonHTTPOutcomes marks links with a non-HTTP scheme as such, they are never requested.
+func nonHTTPOutcomes(links []linkRef) []linkOutcome {
+	outcomes := make([]linkOutcome, len(links))
+	for i, link := range links {
+		outcomes[i] = linkOutcome{link: link, status: linkNonHTTP}
+	}
+	return outcomes
+}
+
+// tallyNonHTTPLink counts link under its scheme. javascript: links are also listed, as they do nothing without
+// scripting, are invisible to assistive technology as navigation targets and are a common injection vector.
+func tallyNonHTTPLink(link linkRef, result *model.AnalyzerResult) {
+	var scheme string
+	if u, err := url.Parse(link.url); err == nil {
+		scheme = u.Scheme
+	}
+
+	switch scheme {
+	case "mailto":
+		result.NonHTTPLinks.Mailto++
+	case "tel":
+		result.NonHTTPLinks.Tel++
+	case "javascript":
+		result.NonHTTPLinks.JavaScript++
+		result.JavaScriptLinks = append(result.JavaScriptLinks, link.href)
+	case "data":
+		result.NonHTTPLinks.Data++
+	case "ftp", "ftps":
+		result.NonHTTPLinks.FTP++
+	default:
+		result.NonHTTPLinks.Other++
+	}
+}
@@ -0,0 +1,309 @@
+package urlanalyzer
+
+import (
+	"fmt"
+	"github.com/sendurangr/url-analyzer-api/internal/constants"
+	"github.com/sendurangr/url-analyzer-api/internal/utils"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Extractor names a single DOM extraction stage that can be toggled per request.
+type Extractor string
+
+const (
+	ExtractorHTMLVersion Extractor = "htmlVersion"
+	ExtractorTitle       Extractor = "title"
+	ExtractorHeadings    Extractor = "headings"
+	ExtractorLinks       Extractor = "links"
+	ExtractorLoginForm   Extractor = "loginForm"
+	ExtractorResources   Extractor = "resources"
+)
+
+// AllExtractors lists every extractor the analyzer supports, in the order they are documented.
+var AllExtractors = []Extractor{
+	ExtractorHTMLVersion,
+	ExtractorTitle,
+	ExtractorHeadings,
+	ExtractorLinks,
+	ExtractorLoginForm,
+	ExtractorResources,
+}
+
+// Mode is a named preset of AnalyzeOptions.
+type Mode string
+
+const (
+	// ModeStandard is the default behaviour - every extractor, link checks bounded by the default limits.
+	ModeStandard Mode = "standard"
+	// ModeMetadata skips link checking entirely, so the response only costs the page fetch.
+	ModeMetadata Mode = "metadata"
+	// ModeExhaustive checks every link with the most generous limits the API allows.
+	ModeExhaustive Mode = "exhaustive"
+)
+
+// LinkCheckMethod selects how links are requested when checking them.
+type LinkCheckMethod string
+
+const (
+	// LinkCheckHead only sends HEAD requests - the cheapest, but some servers reject HEAD outright
+	LinkCheckHead LinkCheckMethod = "head"
+	// LinkCheckGet only sends ranged GET requests
+	LinkCheckGet LinkCheckMethod = "get"
+	// LinkCheckHeadThenGet retries with a ranged GET when HEAD is rejected or answered suspiciously
+	LinkCheckHeadThenGet LinkCheckMethod = "head_then_get"
+)
+
+// LinkSampling selects which links are checked when a page has more than MaxLinks of them.
+type LinkSampling string
+
+const (
+	// SampleFirst checks the first MaxLinks links in document order
+	SampleFirst LinkSampling = "first"
+	// SampleStratified splits MaxLinks between internal and external links in proportion to how many there are,
+	// taking the first ones of each in document order
+	SampleStratified LinkSampling = "stratified"
+	// SampleRandom checks a random selection of MaxLinks links, reproducible with the same SampleSeed
+	SampleRandom LinkSampling = "random"
+)
+
+// AnalyzeOptions controls which stages of AnalyzePage run and how far each one may go.
+// Zero values fall back to the defaults in the constants package.
+type AnalyzeOptions struct {
+	// SkipLinkCheck still classifies links as internal/external but does not request them.
+	SkipLinkCheck bool
+	// MaxLinks caps how many links are checked for accessibility, the rest are reported as unchecked.
+	MaxLinks int
+	// LinkSampling picks the links that are checked when there are more than MaxLinks, defaults to SampleFirst.
+	LinkSampling LinkSampling
+	// SampleSeed seeds SampleRandom. 0 picks a seed, which is reported back so the sample can be reproduced.
+	SampleSeed int64
+	// LinkCheckConcurrency bounds the number of in-flight link checks.
+	LinkCheckConcurrency int
+	// FetchTimeout bounds fetching and parsing the analyzed page.
+	FetchTimeout time.Duration
+	// LinkCheckTimeout bounds the whole link-checking stage.
+	LinkCheckTimeout time.Duration
+	// Extractors selects the extractors to run. Empty means all of them.
+	Extractors []Extractor
+	// MaxRedirects bounds how many redirects are followed when fetching the page. Nil falls back to the default,
+	// 0 follows none.
+	MaxRedirects *int
+	// MaxBodyBytes bounds how much of the page body is read.
+	MaxBodyBytes int64
+	// FailOnBodyTooLarge returns a CodeBodyTooLarge error instead of analyzing a truncated body.
+	FailOnBodyTooLarge bool
+	// RespectRobots refuses pages and skips links disallowed by robots.txt, and honors its Crawl-delay. Every request
+	// then identifies itself with RobotsUserAgent.
+	RespectRobots bool
+	// RobotsUserAgent is the user-agent token robots.txt rules are evaluated for.
+	RobotsUserAgent string
+	// IncludeLinkDetails adds the per-link report (AnalyzerResult.Links) to the result.
+	IncludeLinkDetails bool
+	// LinkCheckMethod defaults to LinkCheckHeadThenGet.
+	LinkCheckMethod LinkCheckMethod
+	// PerHostConcurrency bounds the number of in-flight link checks against a single host.
+	PerHostConcurrency int
+	// HostDelay is the minimum delay between two link checks against the same host.
+	HostDelay time.Duration
+	// RateLimitRetries is how often a link answering 429 (or 503 with Retry-After) is retried after backing off.
+	// Nil falls back to the default, 0 never retries.
+	RateLimitRetries *int
+	// SameSitePolicy decides which links count as internal, defaults to SameSiteExactHost.
+	SameSitePolicy SameSitePolicy
+	// InternalHosts are the hosts treated as internal besides the page's own, used with SameSiteHostList.
+	InternalHosts []string
+	// CheckInternalFragments fetches other internal pages to verify the fragments linked to on them. Fragments into
+	// the analyzed page itself are always verified.
+	CheckInternalFragments bool
+	// CheckResources lists the resource types whose reachability is checked. Empty means inventory only.
+	CheckResources []ResourceType
+	// BypassLinkCache checks every link afresh instead of reusing outcomes cached by earlier analyses. The fresh
+	// outcomes still refresh the cache.
+	BypassLinkCache bool
+	// DetectSoft404 flags pages served with a success status that are really "not found" pages, both the analyzed
+	// page and accessible links. It costs an extra GET per accessible link and one probe request per host.
+	DetectSoft404 bool
+	// Progress, when set, is told about each stage of the analysis as it completes.
+	Progress ProgressFunc
+}
+
+// DefaultAnalyzeOptions returns the options used when the caller does not override anything.
+func DefaultAnalyzeOptions() AnalyzeOptions {
+	return AnalyzeOptions{}.withDefaults()
+}
+
+// OptionsForMode returns the preset options for the given mode.
+func OptionsForMode(mode Mode) (AnalyzeOptions, error) {
+	switch mode {
+	case "", ModeStandard:
+		return DefaultAnalyzeOptions(), nil
+	case ModeMetadata:
+		opts := DefaultAnalyzeOptions()
+		opts.SkipLinkCheck = true
+		return opts, nil
+	case ModeExhaustive:
+		opts := DefaultAnalyzeOptions()
+		opts.FetchTimeout = constants.MaxFetchTimeout
+		opts.LinkCheckTimeout = constants.MaxLinkCheckTimeout
+		opts.MaxBodyBytes = constants.MaxBodyBytesLimit
+		opts.MaxLinks = constants.MaxLinksLimit
+		opts.CheckResources = AllResourceTypes
+		return opts, nil
+	default:
+		return AnalyzeOptions{}, fmt.Errorf("unknown mode %q", mode)
+	}
+}
+
+// Validate rejects options outside the bounds the API is willing to serve. Zero values are always accepted.
+func (o AnalyzeOptions) Validate() error {
+	if o.MaxLinks < 0 || o.MaxLinks > constants.MaxLinksLimit {
+		return fmt.Errorf("maxLinks must be between 0 and %d", constants.MaxLinksLimit)
+	}
+	switch o.LinkSampling {
+	case "", SampleFirst, SampleStratified:
+		if o.SampleSeed != 0 {
+			return fmt.Errorf("sampleSeed requires %q link sampling", SampleRandom)
+		}
+	case SampleRandom:
+	default:
+		return fmt.Errorf("unknown link sampling %q", o.LinkSampling)
+	}
+	if o.LinkCheckConcurrency < 0 || o.LinkCheckConcurrency > constants.MaxLinkCheckConcurrency {
+		return fmt.Errorf("linkCheckConcurrency must be between 0 and %d", constants.MaxLinkCheckConcurrency)
+	}
+	if o.FetchTimeout < 0 || o.FetchTimeout > constants.MaxFetchTimeout {
+		return fmt.Errorf("fetch timeout must be between 0 and %s", constants.MaxFetchTimeout)
+	}
+	if o.LinkCheckTimeout < 0 || o.LinkCheckTimeout > constants.MaxLinkCheckTimeout {
+		return fmt.Errorf("link check timeout must be between 0 and %s", constants.MaxLinkCheckTimeout)
+	}
+	if o.MaxRedirects != nil && (*o.MaxRedirects < 0 || *o.MaxRedirects > constants.MaxRedirectsLimit) {
+		return fmt.Errorf("maxRedirects must be between 0 and %d", constants.MaxRedirectsLimit)
+	}
+	if o.MaxBodyBytes < 0 || o.MaxBodyBytes > constants.MaxBodyBytesLimit {
+		return fmt.Errorf("maxBodyBytes must be between 0 and %d", constants.MaxBodyBytesLimit)
+	}
+	if o.PerHostConcurrency < 0 || o.PerHostConcurrency > constants.MaxLinkCheckConcurrency {
+		return fmt.Errorf("perHostConcurrency must be between 0 and %d", constants.MaxLinkCheckConcurrency)
+	}
+	if o.HostDelay < 0 || o.HostDelay > constants.MaxCrawlDelay {
+		return fmt.Errorf("host delay must be between 0 and %s", constants.MaxCrawlDelay)
+	}
+	if o.RateLimitRetries != nil && (*o.RateLimitRetries < 0 || *o.RateLimitRetries > constants.MaxRateLimitRetries) {
+		return fmt.Errorf("rateLimitRetries must be between 0 and %d", constants.MaxRateLimitRetries)
+	}
+	switch o.LinkCheckMethod {
+	case "", LinkCheckHead, LinkCheckGet, LinkCheckHeadThenGet:
+	default:
+		return fmt.Errorf("unknown link check method %q", o.LinkCheckMethod)
+	}
+	switch o.SameSitePolicy {
+	case "", SameSiteExactHost, SameSiteRegistrableDomain:
+		if len(o.InternalHosts) > 0 {
+			return fmt.Errorf("internalHosts requires the %q same-site policy", SameSiteHostList)
+		}
+	case SameSiteHostList:
+		if len(o.InternalHosts) == 0 {
+			return fmt.Errorf("the %q same-site policy requires internalHosts", SameSiteHostList)
+		}
+	default:
+		return fmt.Errorf("unknown same-site policy %q", o.SameSitePolicy)
+	}
+	for _, t := range o.CheckResources {
+		if !isKnownResourceType(t) {
+			return fmt.Errorf("unknown resource type %q", t)
+		}
+	}
+	for _, e := range o.Extractors {
+		if !isKnownExtractor(e) {
+			return fmt.Errorf("unknown extractor %q", e)
+		}
+	}
+	return nil
+}
+
+func (o AnalyzeOptions) withDefaults() AnalyzeOptions {
+	if o.MaxLinks == 0 {
+		o.MaxLinks = constants.DefaultMaxLinks
+	}
+	if o.LinkSampling == "" {
+		o.LinkSampling = SampleFirst
+	}
+	for o.LinkSampling == SampleRandom && o.SampleSeed == 0 {
+		o.SampleSeed = rand.Int64()
+	}
+	if o.LinkCheckConcurrency == 0 {
+		o.LinkCheckConcurrency = constants.LinkCheckerConcurrentLimit
+	}
+	if o.FetchTimeout == 0 {
+		o.FetchTimeout = constants.ContextTimeout
+	}
+	if o.LinkCheckTimeout == 0 {
+		o.LinkCheckTimeout = constants.ContextTimeout
+	}
+	if o.MaxRedirects == nil {
+		maxRedirects := constants.DefaultMaxRedirects
+		o.MaxRedirects = &maxRedirects
+	}
+	if o.MaxBodyBytes == 0 {
+		o.MaxBodyBytes = constants.DefaultMaxBodyBytes
+	}
+	if o.RobotsUserAgent == "" {
+		o.RobotsUserAgent = constants.DefaultRobotsUserAgent
+	}
+	if o.LinkCheckMethod == "" {
+		o.LinkCheckMethod = LinkCheckHeadThenGet
+	}
+	if o.SameSitePolicy == "" {
+		o.SameSitePolicy = SameSiteExactHost
+	}
+	if o.PerHostConcurrency == 0 {
+		o.PerHostConcurrency = constants.DefaultPerHostConcurrency
+	}
+	if o.RateLimitRetries == nil {
+		retries := constants.DefaultRateLimitRetries
+		o.RateLimitRetries = &retries
+	}
+	return o
+}
+
+// enabledExtractors resolves Extractors into a lookup set, treating an empty list as "all".
+func (o AnalyzeOptions) enabledExtractors() map[Extractor]bool {
+	list := o.Extractors
+	if len(list) == 0 {
+		list = AllExtractors
+	}
+
+	enabled := make(map[Extractor]bool, len(list))
+	for _, e := range list {
+		enabled[e] = true
+	}
+	return enabled
+}
+
+func isKnownExtractor(e Extractor) bool {
+	for _, known := range AllExtractors {
+		if e == known {
+			return true
+		}
+	}
+	return false
+}
+
+// userAgent is the User-Agent requests identify themselves with, empty to keep the default one. When robots.txt is
+// respected it names RobotsUserAgent, so site owners can tell which of their rules the requests follow.
+func (o AnalyzeOptions) userAgent() string {
+	if !o.RespectRobots {
+		return ""
+	}
+	return utils.CrawlerUserAgent(o.RobotsUserAgent)
+}
+
+// identify sets userAgent on req unless it is empty.
+func identify(req *http.Request, userAgent string) {
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+}
@@ -41,17 +41,173 @@ func extractTitleFromElementNode(n *html.Node, result *model.AnalyzerResult) {
 	}
 }
 
-func extractLinksFromElementNode(n *html.Node, baseURL *url.URL, links *[]string) {
+// linkRef is a link found in the document.
+type linkRef struct {
+	// href is the attribute value as written in the document
+	href string
+	// url is href resolved against the page URL
+	url  string
+	text string
+	// occurrences counts how often the url appears in the document, set by dedupeLinks
+	occurrences int
+	// rel holds the lowercased rel tokens, target the raw target attribute
+	rel    []string
+	target string
+}
+
+// documentBaseURL returns the URL relative links in the document resolve against: the href of the first <base>
+// element that has one, resolved against the page URL, or the page URL itself. A base href that does not parse or
+// is not http(s) is ignored, as browsers refuse data: and javascript: base URLs.
+func documentBaseURL(doc *html.Node, pageURL *url.URL) (base *url.URL, href string) {
+	var find func(*html.Node) bool
+	find = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Base {
+			for _, attr := range n.Attr {
+				if attr.Key == "href" {
+					href = attr.Val
+					return true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if find(c) {
+				return true
+			}
+		}
+		return false
+	}
+	if !find(doc) {
+		return pageURL, ""
+	}
+
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return pageURL, href
+	}
+	base = pageURL.ResolveReference(ref)
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return pageURL, href
+	}
+	return base, href
+}
+
+// pageLinks is what the links extractor collects from a document.
+type pageLinks struct {
+	links []linkRef
+	// fragments are the links that point at a #fragment, including the ones into the page itself
+	fragments []fragmentRef
+	// targets are the ids and anchor names fragments can point at
+	targets map[string]bool
+	// resources are collected by the resources extractor
+	resources []resourceRef
+}
+
+func newPageLinks() *pageLinks {
+	return &pageLinks{targets: make(map[string]bool)}
+}
+
+func extractLinksFromElementNode(n *html.Node, baseURL *url.URL, pl *pageLinks) {
+	href := attrValue(n, "href")
+	if href == "" {
+		return
+	}
+
+	linkURL, err := url.Parse(href)
+	if err != nil {
+		return
+	}
+	absURL := baseURL.ResolveReference(linkURL)
+	text := nodeText(n)
+
+	if absURL.Fragment != "" && (absURL.Scheme == "http" || absURL.Scheme == "https") {
+		pl.fragments = append(pl.fragments, fragmentRef{
+			href:     href,
+			text:     text,
+			page:     normalizeLinkURL(absURL),
+			fragment: absURL.Fragment,
+		})
+	}
+	// an in-page anchor is not a link to another resource
+	if strings.HasPrefix(href, "#") {
+		return
+	}
+
+	pl.links = append(pl.links, linkRef{
+		href:        href,
+		url:         normalizeLinkURL(absURL),
+		text:        text,
+		occurrences: 1,
+		rel:         strings.Fields(strings.ToLower(attrValue(n, "rel"))),
+		target:      attrValue(n, "target"),
+	})
+}
+
+// collectFragmentTargets records the id of any element, and the name of <a> elements, as fragment targets.
+func collectFragmentTargets(n *html.Node, targets map[string]bool) {
 	for _, attr := range n.Attr {
-		if attr.Key != "href" || attr.Val == "" || strings.HasPrefix(attr.Val, "#") {
+		if attr.Val == "" {
 			continue
 		}
+		if attr.Key == "id" || (attr.Key == "name" && n.DataAtom == atom.A) {
+			targets[attr.Val] = true
+		}
+	}
+}
+
+// normalizeLinkURL puts an absolute link into a canonical form, so that spellings of the same URL compare equal:
+// the scheme and host are lowercased, default ports and the fragment are dropped, and an empty path becomes "/".
+func normalizeLinkURL(u *url.URL) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	n.Fragment, n.RawFragment = "", ""
 
-		if linkURL, err := url.Parse(attr.Val); err == nil {
-			absURL := baseURL.ResolveReference(linkURL)
-			*links = append(*links, absURL.String())
+	if port := n.Port(); (n.Scheme == "http" && port == "80") || (n.Scheme == "https" && port == "443") {
+		n.Host = strings.TrimSuffix(n.Host, ":"+port)
+	}
+	if n.Path == "" && n.Opaque == "" && n.Host != "" {
+		n.Path = "/"
+	}
+	return n.String()
+}
+
+// dedupeLinks merges links to the same URL into the first one, keeping document order and counting occurrences.
+func dedupeLinks(links []linkRef) []linkRef {
+	seen := make(map[string]int, len(links))
+	unique := links[:0:0]
+
+	for _, link := range links {
+		if i, ok := seen[link.url]; ok {
+			unique[i].occurrences++
+			continue
+		}
+		seen[link.url] = len(unique)
+		unique = append(unique, link)
+	}
+	return unique
+}
+
+// nodeText returns the whitespace-collapsed text content of n, cut to constants.MaxAnchorTextLength runes.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			sb.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
 		}
 	}
+	collect(n)
+
+	text := []rune(strings.Join(strings.Fields(sb.String()), " "))
+	if len(text) > constants.MaxAnchorTextLength {
+		text = text[:constants.MaxAnchorTextLength]
+	}
+	return string(text)
 }
 
 func detectLoginFormFromElementNode(n *html.Node, result *model.AnalyzerResult) {
@@ -0,0 +1,142 @@
+package urlanalyzer
+
+import (
+	"context"
+	"fmt"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeLinkURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://example.com", "https://example.com/"},
+		{"HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+		{"https://example.com/a?x=1#section", "https://example.com/a?x=1"},
+		{"mailto:someone@example.com", "mailto:someone@example.com"},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.raw)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", tt.raw, err)
+		}
+		if got := normalizeLinkURL(u); got != tt.want {
+			t.Errorf("normalizeLinkURL(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzePage_LinkDeduplication(t *testing.T) {
+	simServer := simulateSuccessAndFailServer()
+	defer simServer.Close()
+
+	html := fmt.Sprintf(`
+		<html><body>
+			<nav><a href="/">Home</a><a href="%[1]s/ok">Shop</a></nav>
+			<a href="%[1]s/ok#top">Shop again</a>
+			<a href="%[1]s/fail">Broken</a>
+			<footer><a href="/#footer">Home</a><a href="%[1]s/ok">Shop</a></footer>
+		</body></html>
+	`, simServer.URL)
+
+	ts := startTestServer(html)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	result, err := service.AnalyzePage(context.Background(), ts.URL, AnalyzeOptions{IncludeLinkDetails: true})
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	if result.TotalLinks != 6 || result.UniqueLinks != 3 {
+		t.Errorf("expected 6 links of which 3 unique, got %d and %d", result.TotalLinks, result.UniqueLinks)
+	}
+	if result.InternalLinks != 1 || result.ExternalLinks != 2 || result.LinksChecked != 3 {
+		t.Errorf("expected counters per unique URL, got %+v", result)
+	}
+	if result.InaccessibleExternalLinks != 1 {
+		t.Errorf("expected 1 inaccessible external link, got %d", result.InaccessibleExternalLinks)
+	}
+
+	want := []model.LinkOccurrence{{URL: ts.URL + "/", Count: 2}, {URL: simServer.URL + "/ok", Count: 3}}
+	if len(result.RepeatedLinks) != len(want) {
+		t.Fatalf("expected repeated links %v, got %v", want, result.RepeatedLinks)
+	}
+	for i := range want {
+		if result.RepeatedLinks[i] != want[i] {
+			t.Errorf("repeated link %d: expected %v, got %v", i, want[i], result.RepeatedLinks[i])
+		}
+	}
+
+	if got := result.Links[1]; got.Text != "Shop" || got.Occurrences != 3 {
+		t.Errorf("expected the first occurrence to describe the link, got %+v", got)
+	}
+}
+
+func TestDocumentBaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		wantBase string
+		wantHref string
+	}{
+		{name: "no base", doc: `<a href="x">x</a>`, wantBase: "https://example.com/docs/page"},
+		{name: "absolute base", doc: `<base href="https://cdn.example.com/app/">`, wantBase: "https://cdn.example.com/app/", wantHref: "https://cdn.example.com/app/"},
+		{name: "relative base", doc: `<base href="/app/">`, wantBase: "https://example.com/app/", wantHref: "/app/"},
+		{name: "first base with href wins", doc: `<base target="_blank"><base href="/one/"><base href="/two/">`, wantBase: "https://example.com/one/", wantHref: "/one/"},
+		{name: "javascript base ignored", doc: `<base href="javascript:alert(1)">`, wantBase: "https://example.com/docs/page", wantHref: "javascript:alert(1)"},
+	}
+
+	pageURL, _ := url.Parse("https://example.com/docs/page")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader("<html><head>" + tt.doc + "</head><body></body></html>"))
+			if err != nil {
+				t.Fatalf("failed to parse document: %v", err)
+			}
+
+			base, href := documentBaseURL(doc, pageURL)
+			if base.String() != tt.wantBase || href != tt.wantHref {
+				t.Errorf("expected base %q (href %q), got %q (href %q)", tt.wantBase, tt.wantHref, base, href)
+			}
+		})
+	}
+}
+
+func TestAnalyzePage_BaseElement(t *testing.T) {
+	simServer := simulateSuccessAndFailServer()
+	defer simServer.Close()
+
+	// the links would all be broken if they were resolved against the page URL
+	html := fmt.Sprintf(`<html><head><base href="%s/"></head><body>
+		<a href="ok">Relative</a>
+		<a href="/ok">Root relative</a>
+	</body></html>`, simServer.URL)
+
+	ts := startTestServer(html)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	result, err := service.AnalyzePage(context.Background(), ts.URL, DefaultAnalyzeOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	if result.BaseURL != simServer.URL+"/" || result.BaseHref != simServer.URL+"/" {
+		t.Errorf("expected the base element to be reported, got %q (href %q)", result.BaseURL, result.BaseHref)
+	}
+	if result.UniqueLinks != 1 || result.ExternalLinks != 1 || result.InaccessibleExternalLinks != 0 {
+		t.Errorf("expected both links to resolve to %s/ok, got %+v", simServer.URL, result)
+	}
+}
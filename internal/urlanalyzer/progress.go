@@ -0,0 +1,63 @@
+package urlanalyzer
+
+import (
+	"github.com/sendurangr/url-analyzer-api/internal/constants"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"sync"
+)
+
+// Stages reported through AnalyzeOptions.Progress, in the order they happen
+const (
+	StageFetched   = "fetched"
+	StageParsed    = "parsed"
+	StageMetadata  = "metadata"
+	StageLinkCheck = "link_check"
+)
+
+// ProgressFunc receives progress events while a page is analyzed. It is never called concurrently, and never
+// after AnalyzePage has returned.
+type ProgressFunc func(model.ProgressEvent)
+
+func (o AnalyzeOptions) reportProgress(event model.ProgressEvent) {
+	if o.Progress != nil {
+		o.Progress(event)
+	}
+}
+
+// linkProgress keeps the running counts of a link-check stage and reports them every
+// constants.LinkProgressBatchSize links, and once more when the last link is done.
+type linkProgress struct {
+	report ProgressFunc
+
+	mu     sync.Mutex
+	counts model.LinkProgress
+}
+
+func newLinkProgress(report ProgressFunc, total int) *linkProgress {
+	return &linkProgress{report: report, counts: model.LinkProgress{Total: total}}
+}
+
+func (p *linkProgress) add(outcome linkOutcome) {
+	if p.report == nil {
+		return
+	}
+
+	// holding the lock while reporting keeps the events in order and the callback serialized
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.counts.Done++
+	switch outcome.status {
+	case linkAccessible:
+		p.counts.Accessible++
+	case linkInaccessible:
+		p.counts.Inaccessible++
+	case linkUnchecked:
+		p.counts.Unchecked++
+	}
+
+	if p.counts.Done%constants.LinkProgressBatchSize == 0 || p.counts.Done == p.counts.Total {
+		counts := p.counts
+		p.report(model.ProgressEvent{Stage: StageLinkCheck, Links: &counts})
+	}
+}
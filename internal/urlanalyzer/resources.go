@@ -0,0 +1,253 @@
+package urlanalyzer
+
+import (
+	"context"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"net/url"
+	"strings"
+)
+
+// ResourceType groups the resources a page references besides its <a> links.
+type ResourceType string
+
+const (
+	ResourceImage      ResourceType = "image"
+	ResourceScript     ResourceType = "script"
+	ResourceStylesheet ResourceType = "stylesheet"
+	// ResourceLinkHint covers every other <link href>: icons, preloads, manifests, alternates and the like
+	ResourceLinkHint ResourceType = "link"
+	ResourceIframe   ResourceType = "iframe"
+	ResourceArea     ResourceType = "area"
+	// ResourceSource is <source src> and <source srcset> inside <picture>, <video> and <audio>
+	ResourceSource ResourceType = "source"
+	// ResourceMedia is <video>/<audio> src, <video poster> and <track src>
+	ResourceMedia ResourceType = "media"
+	ResourceForm  ResourceType = "form"
+)
+
+// AllResourceTypes lists every resource type the inventory knows, in the order they are documented.
+var AllResourceTypes = []ResourceType{
+	ResourceImage,
+	ResourceScript,
+	ResourceStylesheet,
+	ResourceLinkHint,
+	ResourceIframe,
+	ResourceArea,
+	ResourceSource,
+	ResourceMedia,
+	ResourceForm,
+}
+
+func isKnownResourceType(t ResourceType) bool {
+	for _, known := range AllResourceTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceRef is a resource referenced by the document.
+type resourceRef struct {
+	kind ResourceType
+	link linkRef
+}
+
+// extractResourcesFromElementNode records the resources n references.
+func extractResourcesFromElementNode(n *html.Node, baseURL *url.URL, resources *[]resourceRef) {
+	add := func(kind ResourceType, value string) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return
+		}
+		if ref, err := url.Parse(value); err == nil {
+			absURL := baseURL.ResolveReference(ref)
+			*resources = append(*resources, resourceRef{
+				kind: kind,
+				link: linkRef{href: value, url: normalizeLinkURL(absURL), occurrences: 1},
+			})
+		}
+	}
+	addSrcset := func(kind ResourceType, srcset string) {
+		for _, candidate := range srcsetURLs(srcset) {
+			add(kind, candidate)
+		}
+	}
+
+	switch n.DataAtom {
+	case atom.Img:
+		add(ResourceImage, attrValue(n, "src"))
+		addSrcset(ResourceImage, attrValue(n, "srcset"))
+	case atom.Input:
+		if strings.EqualFold(attrValue(n, "type"), "image") {
+			add(ResourceImage, attrValue(n, "src"))
+		}
+	case atom.Script:
+		add(ResourceScript, attrValue(n, "src"))
+	case atom.Link:
+		kind := ResourceLinkHint
+		for _, rel := range strings.Fields(strings.ToLower(attrValue(n, "rel"))) {
+			if rel == "stylesheet" {
+				kind = ResourceStylesheet
+			}
+		}
+		add(kind, attrValue(n, "href"))
+	case atom.Iframe, atom.Frame:
+		add(ResourceIframe, attrValue(n, "src"))
+	case atom.Area:
+		add(ResourceArea, attrValue(n, "href"))
+	case atom.Source:
+		add(ResourceSource, attrValue(n, "src"))
+		addSrcset(ResourceSource, attrValue(n, "srcset"))
+	case atom.Video, atom.Audio:
+		add(ResourceMedia, attrValue(n, "src"))
+		add(ResourceMedia, attrValue(n, "poster"))
+	case atom.Track:
+		add(ResourceMedia, attrValue(n, "src"))
+	case atom.Form:
+		add(ResourceForm, attrValue(n, "action"))
+	}
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// srcsetURLs returns the candidate URLs of a srcset, "a.jpg 1x, b.jpg 2x", following the HTML parsing rules: a URL
+// runs up to whitespace and may itself contain commas, only the descriptor after it is ended by one.
+func srcsetURLs(srcset string) []string {
+	var urls []string
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r' }
+
+	for i := 0; i < len(srcset); {
+		if isSpace(srcset[i]) || srcset[i] == ',' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(srcset) && !isSpace(srcset[i]) {
+			i++
+		}
+		candidate := srcset[start:i]
+		// commas right after a URL end the candidate, there is no descriptor to skip then
+		if trimmed := strings.TrimRight(candidate, ","); trimmed != candidate {
+			if trimmed != "" {
+				urls = append(urls, trimmed)
+			}
+			continue
+		}
+		urls = append(urls, candidate)
+
+		// the descriptor ends at the next comma outside parentheses
+		depth := 0
+	descriptor:
+		for ; i < len(srcset); i++ {
+			switch srcset[i] {
+			case '(':
+				depth++
+			case ')':
+				depth = max(depth-1, 0)
+			case ',':
+				if depth == 0 {
+					break descriptor
+				}
+			}
+		}
+	}
+	return urls
+}
+
+// inventoryResources counts the resources by type and, for the types in opts.CheckResources, checks whether they
+// are reachable with the same machinery as links, within the link-check run of the page.
+func (a *analyzer) inventoryResources(ctx context.Context, resources []resourceRef, run *linkCheckRun, opts AnalyzeOptions) model.Resources {
+	inventory := model.Resources{ByType: make(map[string]model.ResourceGroup)}
+
+	byKind := make(map[ResourceType][]linkRef)
+	for _, r := range resources {
+		byKind[r.kind] = append(byKind[r.kind], r.link)
+	}
+
+	// progress events describe the page's links, not its resources, and a resource being a "not found" page is
+	// neither reported nor worth an extra GET per resource
+	opts.Progress = nil
+	opts.DetectSoft404 = false
+	resourceRun := *run
+	resourceRun.probes = nil
+
+	check := make(map[ResourceType]bool, len(opts.CheckResources))
+	for _, kind := range opts.CheckResources {
+		check[kind] = true
+	}
+
+	// every selected type is checked in one go, sharing the link-check budget and host throttle of the page
+	var checkable []linkRef
+	for _, kind := range AllResourceTypes {
+		if check[kind] && !opts.SkipLinkCheck {
+			// data: images and the like are embedded in the page, there is nothing to request
+			links, _ := splitByScheme(dedupeLinks(byKind[kind]))
+			checkable = append(checkable, links...)
+		}
+	}
+	outcomes := make(map[string]linkOutcome)
+	if len(checkable) > 0 {
+		// a URL referenced as two types, say a preloaded image, is requested once
+		checkable = dedupeLinks(checkable)
+		for _, o := range a.checkLinksConcurrently(ctx, sampleLinks(checkable, run.site, opts), &resourceRun, opts) {
+			outcomes[o.link.url] = o
+		}
+	}
+
+	for _, kind := range AllResourceTypes {
+		refs := byKind[kind]
+		if len(refs) == 0 {
+			continue
+		}
+
+		unique := dedupeLinks(refs)
+		group := model.ResourceGroup{Count: len(refs), Unique: len(unique)}
+		inventory.Total += len(refs)
+
+		for _, link := range unique {
+			o, ok := outcomes[link.url]
+			if !ok || !check[kind] {
+				continue
+			}
+			if o.status == linkUnchecked || o.status == linkRateLimited {
+				group.Unchecked++
+				continue
+			}
+			group.Checked++
+			if o.status == linkInaccessible {
+				group.Inaccessible++
+				inventory.Broken = append(inventory.Broken, model.BrokenResource{
+					Type:          string(kind),
+					URL:           o.link.url,
+					StatusCode:    o.statusCode,
+					ErrorCategory: o.errorCat,
+				})
+			}
+		}
+
+		inventory.ByType[string(kind)] = group
+	}
+
+	return inventory
+}
+
+// hasUncheckedResources reports whether some resources that were meant to be checked have no verdict.
+func hasUncheckedResources(inventory model.Resources) bool {
+	for _, group := range inventory.ByType {
+		if group.Unchecked > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package urlanalyzer
+
+import (
+	"context"
+	"fmt"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAnalyzePage_Resources(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<html>
+			<head>
+				<link rel="stylesheet" href="/main.css">
+				<link rel="Preload Stylesheet" href="/missing.css">
+				<link rel="icon" href="/favicon.ico">
+				<script src="/app.js"></script>
+				<script>inline()</script>
+			</head>
+			<body>
+				<img src="/logo.png" srcset="/logo.png 1x, /logo@2x.png 2x">
+				<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=">
+				<picture><source srcset="/hero.webp"></picture>
+				<video src="/intro.mp4" poster="/poster.jpg"><track src="/intro.vtt"></video>
+				<iframe src="/embed"></iframe>
+				<map><area href="/region"></map>
+				<form action="/login"></form>
+				<form></form>
+			</body>
+		</html>`)
+	})
+	for _, path := range []string{"/main.css", "/favicon.ico", "/app.js", "/logo.png", "/logo@2x.png"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {})
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	opts := AnalyzeOptions{CheckResources: []ResourceType{ResourceStylesheet, ResourceImage}}
+	result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts)
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	want := map[string]model.ResourceGroup{
+		"image":      {Count: 4, Unique: 3, Checked: 2, Inaccessible: 0},
+		"script":     {Count: 1, Unique: 1},
+		"stylesheet": {Count: 2, Unique: 2, Checked: 2, Inaccessible: 1},
+		"link":       {Count: 1, Unique: 1},
+		"iframe":     {Count: 1, Unique: 1},
+		"area":       {Count: 1, Unique: 1},
+		"source":     {Count: 1, Unique: 1},
+		"media":      {Count: 3, Unique: 3},
+		"form":       {Count: 1, Unique: 1},
+	}
+	if len(result.Resources.ByType) != len(want) {
+		t.Errorf("expected resource types %v, got %v", want, result.Resources.ByType)
+	}
+	for kind, group := range want {
+		if got := result.Resources.ByType[kind]; got != group {
+			t.Errorf("%s: expected %+v, got %+v", kind, group, got)
+		}
+	}
+	if result.Resources.Total != 15 {
+		t.Errorf("expected 15 resources, got %d", result.Resources.Total)
+	}
+
+	wantBroken := model.BrokenResource{Type: "stylesheet", URL: ts.URL + "/missing.css", StatusCode: 404, ErrorCategory: "4xx"}
+	if len(result.Resources.Broken) != 1 || result.Resources.Broken[0] != wantBroken {
+		t.Errorf("expected %+v to be the only broken resource, got %+v", wantBroken, result.Resources.Broken)
+	}
+}
+
+func TestAnalyzePage_ResourcesShareOneBudget(t *testing.T) {
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<html><head>
+			<link rel="stylesheet" href="/slow.css"><script src="/slow.js"></script>
+		</head><body><img src="/slow.png"></body></html>`)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	defer close(release)
+
+	service := NewAnalyzer(httpClient)
+
+	budget := 200 * time.Millisecond
+	opts := AnalyzeOptions{LinkCheckTimeout: budget, CheckResources: []ResourceType{ResourceImage, ResourceScript, ResourceStylesheet}}
+	start := time.Now()
+	result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts)
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	// one budget per type would take three times as long
+	if elapsed := time.Since(start); elapsed > 2*budget {
+		t.Errorf("expected every resource type to share one link-check budget, took %v", elapsed)
+	}
+	if len(result.Resources.Broken) != 0 {
+		t.Errorf("expected resources that ran out of time not to be reported as broken, got %+v", result.Resources.Broken)
+	}
+	for _, kind := range []string{"image", "script", "stylesheet"} {
+		if got := result.Resources.ByType[kind]; got.Checked != 0 || got.Unchecked != 1 {
+			t.Errorf("%s: expected the resource that ran out of time to be counted as unchecked, got %+v", kind, got)
+		}
+	}
+	if !result.Partial {
+		t.Error("expected resources that ran out of time to mark the result as partial")
+	}
+}
+
+func TestSrcsetURLs(t *testing.T) {
+	tests := []struct {
+		name   string
+		srcset string
+		want   []string
+	}{
+		{name: "descriptors", srcset: "/a.jpg 1x, /b.jpg 2x", want: []string{"/a.jpg", "/b.jpg"}},
+		{name: "no descriptors", srcset: "/a.jpg, /b.jpg", want: []string{"/a.jpg", "/b.jpg"}},
+		// without whitespace after the comma it is part of the URL
+		{name: "no separating whitespace", srcset: "/a.jpg,/b.jpg", want: []string{"/a.jpg,/b.jpg"}},
+		{name: "single", srcset: "  /hero.webp  ", want: []string{"/hero.webp"}},
+		{name: "comma in url", srcset: "/img/w_300,h_200/a.jpg 1x, /img/w_600,h_400/a.jpg 2x",
+			want: []string{"/img/w_300,h_200/a.jpg", "/img/w_600,h_400/a.jpg"}},
+		{name: "comma in descriptor parentheses", srcset: "/a.jpg 100w (x,y), /b.jpg 200w", want: []string{"/a.jpg", "/b.jpg"}},
+		{name: "empty candidates", srcset: " , ,/a.jpg 1x,, ", want: []string{"/a.jpg"}},
+		{name: "empty", srcset: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := srcsetURLs(tt.srcset); !slices.Equal(got, tt.want) {
+				t.Errorf("srcsetURLs(%q) = %q, want %q", tt.srcset, got, tt.want)
+			}
+		})
+	}
+}
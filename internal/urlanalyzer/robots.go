@@ -0,0 +1,297 @@
+package urlanalyzer
+
+import (
+	"bufio"
+	"context"
+	"github.com/sendurangr/url-analyzer-api/internal/constants"
+	"github.com/sendurangr/url-analyzer-api/internal/utils"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsGroup is one "User-agent:" block of a robots.txt file.
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow bool
+	// length of the original pattern, the most specific (longest) matching rule wins
+	length int
+	re     *regexp.Regexp
+}
+
+// robotsRules is a parsed robots.txt. A nil *robotsRules allows everything.
+type robotsRules struct {
+	groups []robotsGroup
+	// disallowAll is set when robots.txt was unreachable or failed with a server error, see RFC 9309 section 2.3.1.4
+	disallowAll bool
+}
+
+// parseRobots parses a robots.txt body. Unknown directives and malformed lines are ignored.
+func parseRobots(r io.Reader) *robotsRules {
+	rules := &robotsRules{}
+	var current *robotsGroup
+	// consecutive User-agent lines share one group
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || !lastWasAgent {
+				rules.groups = append(rules.groups, robotsGroup{})
+				current = &rules.groups[len(rules.groups)-1]
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			// an empty Disallow means "allow everything", which is already the default
+			if current != nil && value != "" {
+				current.rules = append(current.rules, robotsRule{
+					allow:  key == "allow",
+					length: len(value),
+					re:     compileRobotsPattern(value),
+				})
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && current != nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+		lastWasAgent = false
+	}
+
+	return rules
+}
+
+// group returns the rules that apply to userAgent: those of every group naming its product token, otherwise those
+// of every "*" group. Names match the token exactly, ignoring case, as RFC 9309 section 2.2.1 asks.
+func (r *robotsRules) group(userAgent string) *robotsGroup {
+	token, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(userAgent)), "/")
+
+	var named, wildcard []*robotsGroup
+	for i := range r.groups {
+		for _, agent := range r.groups[i].agents {
+			if agent == "*" {
+				wildcard = append(wildcard, &r.groups[i])
+			} else if agent == token {
+				named = append(named, &r.groups[i])
+			}
+		}
+	}
+
+	matched := named
+	if len(matched) == 0 {
+		matched = wildcard
+	}
+	switch len(matched) {
+	case 0:
+		return nil
+	case 1:
+		return matched[0]
+	}
+
+	// several groups for the same agent are combined into one
+	merged := &robotsGroup{}
+	for _, g := range matched {
+		merged.rules = append(merged.rules, g.rules...)
+		merged.crawlDelay = max(merged.crawlDelay, g.crawlDelay)
+	}
+	return merged
+}
+
+// allowed reports whether userAgent may fetch u. The longest matching rule wins, Allow wins ties.
+func (r *robotsRules) allowed(userAgent string, u *url.URL) bool {
+	if r == nil {
+		return true
+	}
+	if r.disallowAll {
+		return false
+	}
+
+	g := r.group(userAgent)
+	if g == nil {
+		return true
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	allow, matchLen := true, -1
+	for _, rule := range g.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if rule.length > matchLen || (rule.length == matchLen && rule.allow) {
+			allow, matchLen = rule.allow, rule.length
+		}
+	}
+	return allow
+}
+
+// crawlDelay returns the Crawl-delay that applies to userAgent, capped at constants.MaxCrawlDelay.
+func (r *robotsRules) crawlDelay(userAgent string) time.Duration {
+	if r == nil {
+		return 0
+	}
+	g := r.group(userAgent)
+	if g == nil {
+		return 0
+	}
+	return min(g.crawlDelay, constants.MaxCrawlDelay)
+}
+
+// compileRobotsPattern turns a robots.txt path pattern into a prefix regexp, supporting the "*" wildcard
+// and the "$" end anchor.
+func compileRobotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+type robotsEntry struct {
+	rules *robotsRules
+	// err is the transport error robots.txt could not be fetched with
+	err error
+}
+
+// robotsCache fetches robots.txt at most once per scheme+host for constants.RobotsCacheTTL,
+// shared by every analysis that runs on the same analyzer.
+type robotsCache struct {
+	client *http.Client
+
+	mu      sync.Mutex
+	entries *ttlCache[robotsEntry]
+	// inflight lets concurrent link checks for the same host wait for a single fetch
+	inflight map[string]chan struct{}
+}
+
+func newRobotsCache(client *http.Client) *robotsCache {
+	return &robotsCache{
+		client:   client,
+		entries:  newTTLCache[robotsEntry](constants.RobotsCacheMaxEntries),
+		inflight: make(map[string]chan struct{}),
+	}
+}
+
+// rulesFor returns the robots.txt rules for the host of u, fetching them when they are not cached. It fails with the
+// transport error when robots.txt could not be requested at all, the host is then most likely unreachable anyway.
+// The fetch identifies itself as userAgent.
+func (c *robotsCache) rulesFor(ctx context.Context, u *url.URL, userAgent string) (*robotsRules, error) {
+	key := u.Scheme + "://" + u.Host
+
+	for {
+		c.mu.Lock()
+		if entry, ok := c.entries.get(key); ok {
+			c.mu.Unlock()
+			return entry.rules, entry.err
+		}
+		wait, busy := c.inflight[key]
+		if !busy {
+			done := make(chan struct{})
+			c.inflight[key] = done
+			c.mu.Unlock()
+
+			rules, cacheable, err := c.fetch(ctx, key, userAgent)
+
+			c.mu.Lock()
+			if cacheable {
+				c.store(key, rules, err)
+			}
+			delete(c.inflight, key)
+			c.mu.Unlock()
+			close(done)
+			return rules, err
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// store caches rules, or the error fetching them failed with, for key. Must be called with c.mu held.
+func (c *robotsCache) store(key string, rules *robotsRules, err error) {
+	ttl := constants.RobotsCacheTTL
+	if err != nil {
+		ttl = constants.RobotsFailureCacheTTL
+	}
+	c.entries.put(key, robotsEntry{rules: rules, err: err}, ttl)
+}
+
+// fetch downloads and parses origin's robots.txt. The result is not cacheable when the fetch was cut short by ctx.
+func (c *robotsCache) fetch(ctx context.Context, origin string, userAgent string) (rules *robotsRules, cacheable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	utils.SetHeaders(req)
+	identify(req, userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, err
+		}
+		// the host could not be reached, which says nothing about what it allows
+		slog.Warn("Failed to fetch robots.txt", "origin", origin, "error", err)
+		return nil, true, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Error("Failed to close response body", "error", err)
+		}
+	}()
+
+	switch {
+	case resp.StatusCode >= 500:
+		// a server error on robots.txt means complete disallow
+		return &robotsRules{disallowAll: true}, true, nil
+	case resp.StatusCode >= 400:
+		// no robots.txt, no restrictions
+		return nil, true, nil
+	}
+
+	return parseRobots(io.LimitReader(resp.Body, constants.MaxRobotsBytes)), ctx.Err() == nil, nil
+}
@@ -0,0 +1,245 @@
+package urlanalyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testRobots = `
+# comment
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+
+User-agent: url-analyzer
+User-agent: other-bot
+Disallow: /no-analyzer
+Crawl-delay: 0.1
+`
+
+func TestRobotsRules_Allowed(t *testing.T) {
+	rules := parseRobots(strings.NewReader(testRobots))
+
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{agent: "some-bot", path: "/", want: true},
+		{agent: "some-bot", path: "/private/page", want: false},
+		{agent: "some-bot", path: "/private/public/page", want: true},
+		{agent: "some-bot", path: "/docs/file.pdf", want: false},
+		{agent: "some-bot", path: "/docs/file.pdf?x=1", want: true},
+		{agent: "url-analyzer", path: "/private/page", want: true},
+		{agent: "url-analyzer", path: "/no-analyzer/page", want: false},
+		{agent: "Other-Bot/2.0", path: "/no-analyzer", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.agent+tc.path, func(t *testing.T) {
+			u, _ := url.Parse("https://example.com" + tc.path)
+			if got := rules.allowed(tc.agent, u); got != tc.want {
+				t.Errorf("expected allowed=%v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	if delay := rules.crawlDelay("url-analyzer"); delay != 100*time.Millisecond {
+		t.Errorf("expected a 100ms crawl delay, got %v", delay)
+	}
+}
+
+func TestRobotsRules_Group(t *testing.T) {
+	rules := parseRobots(strings.NewReader(`
+User-agent: url
+Disallow: /substring
+
+User-agent: URL-Analyzer
+Disallow: /first
+
+User-agent: *
+Disallow: /everyone
+
+User-agent: url-analyzer
+Disallow: /second
+Crawl-delay: 2
+`))
+
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		// a group naming a substring of our token does not apply
+		{agent: "url-analyzer", path: "/substring", want: true},
+		// both groups naming the token apply, and only those
+		{agent: "url-analyzer", path: "/first", want: false},
+		{agent: "url-analyzer", path: "/second", want: false},
+		{agent: "url-analyzer", path: "/everyone", want: true},
+		{agent: "url", path: "/substring", want: false},
+		{agent: "url", path: "/first", want: true},
+		{agent: "some-bot", path: "/everyone", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.agent+tc.path, func(t *testing.T) {
+			u, _ := url.Parse("https://example.com" + tc.path)
+			if got := rules.allowed(tc.agent, u); got != tc.want {
+				t.Errorf("expected allowed=%v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	if delay := rules.crawlDelay("url-analyzer"); delay != 2*time.Second {
+		t.Errorf("expected the merged groups' crawl delay, got %v", delay)
+	}
+}
+
+func TestAnalyzePage_RespectRobots(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, testRobots)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `<html><body>
+			<a href="/private/a">Disallowed</a>
+			<a href="/a">One</a>
+			<a href="/b">Two</a>
+			<a href="/c">Three</a>
+		</body></html>`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	t.Run("disallowed page", func(t *testing.T) {
+		opts := AnalyzeOptions{RespectRobots: true, RobotsUserAgent: "some-bot"}
+		_, err := service.AnalyzePage(context.Background(), ts.URL+"/private/page", opts)
+
+		var analyzeErr *AnalyzeError
+		if !errors.As(err, &analyzeErr) || analyzeErr.Code != CodeRobotsDisallowed {
+			t.Fatalf("expected %q error, got %v", CodeRobotsDisallowed, err)
+		}
+	})
+
+	t.Run("skipped links and crawl delay", func(t *testing.T) {
+		opts := AnalyzeOptions{RespectRobots: true, RobotsUserAgent: "some-bot"}
+		result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts)
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if result.InternalLinks != 4 || result.RobotsSkippedLinks != 1 || result.InaccessibleInternalLinks != 0 {
+			t.Errorf("expected 1 of 4 links to be skipped by robots.txt, got %+v", result)
+		}
+
+		start := time.Now()
+		opts.RobotsUserAgent = "url-analyzer"
+		// the first run cached the links, they have to be requested again for the delay to show
+		opts.BypassLinkCache = true
+		if _, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts); err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		// 4 links on the same host, 100ms apart
+		if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+			t.Errorf("expected Crawl-delay to space out link checks, took %v", elapsed)
+		}
+	})
+}
+
+func TestAnalyzePage_RobotsUnreachable(t *testing.T) {
+	// grab a free port and close it again, so that connecting to it is refused
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	refusedURL := closed.URL + "/gone"
+	closed.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "User-agent: *\nAllow: /\n")
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `<html><body><a href="%s">Gone</a></body></html>`, refusedURL)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+	opts := AnalyzeOptions{RespectRobots: true, IncludeLinkDetails: true}
+
+	t.Run("page", func(t *testing.T) {
+		_, err := service.AnalyzePage(context.Background(), refusedURL, opts)
+
+		var analyzeErr *AnalyzeError
+		if !errors.As(err, &analyzeErr) || analyzeErr.Code != CodeConnectionFailed {
+			t.Fatalf("expected %q error, got %v", CodeConnectionFailed, err)
+		}
+	})
+
+	t.Run("link", func(t *testing.T) {
+		result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts)
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if result.RobotsSkippedLinks != 0 || result.InaccessibleExternalLinks != 1 {
+			t.Errorf("expected the unreachable link to be inaccessible rather than skipped, got %+v", result)
+		}
+		if len(result.Links) != 1 || result.Links[0].ErrorCategory != "refused" {
+			t.Errorf("expected the link to fail as refused, got %+v", result.Links)
+		}
+	})
+}
+
+func TestRobotsCache_ServerError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL + "/page")
+	rules, err := newRobotsCache(httpClient).rulesFor(context.Background(), u, "")
+	if err != nil {
+		t.Fatalf("rulesFor failed: %v", err)
+	}
+	if rules.allowed("url-analyzer", u) {
+		t.Error("expected a robots.txt server error to disallow everything")
+	}
+}
+
+func TestAnalyzePage_RobotsUserAgentHeader(t *testing.T) {
+	var mu sync.Mutex
+	agents := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents[r.Method+" "+r.URL.Path] = r.UserAgent()
+		mu.Unlock()
+		if r.URL.Path == "/page" {
+			_, _ = fmt.Fprint(w, `<html><body><a href="/about">About</a></body></html>`)
+		}
+	}))
+	defer ts.Close()
+
+	opts := AnalyzeOptions{RespectRobots: true, RobotsUserAgent: "some-bot"}
+	if _, err := NewAnalyzer(httpClient).AnalyzePage(context.Background(), ts.URL+"/page", opts); err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, request := range []string{"GET /robots.txt", "GET /page", "HEAD /about"} {
+		if agent, ok := agents[request]; !ok || !strings.Contains(agent, "some-bot") {
+			t.Errorf("expected %s to identify as some-bot, got User-Agent %q", request, agent)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package urlanalyzer
+
+import (
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"golang.org/x/net/publicsuffix"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// SameSitePolicy decides which links count as internal.
+type SameSitePolicy string
+
+const (
+	// SameSiteExactHost treats only links to the page's own host (and port) as internal
+	SameSiteExactHost SameSitePolicy = "exact_host"
+	// SameSiteRegistrableDomain treats every host under the page's registrable domain (eTLD+1) as internal,
+	// so www.example.com, example.com and blog.example.com are one site
+	SameSiteRegistrableDomain SameSitePolicy = "registrable_domain"
+	// SameSiteHostList treats the page's host plus AnalyzeOptions.InternalHosts as internal
+	SameSiteHostList SameSitePolicy = "host_list"
+)
+
+// sameSite classifies links as internal or external relative to the analyzed page.
+type sameSite struct {
+	policy SameSitePolicy
+	// host is the page's host with any default port dropped
+	host string
+	// domain is the page's registrable domain, only set for SameSiteRegistrableDomain
+	domain string
+	hosts  []string
+}
+
+func newSameSite(pageURL *url.URL, opts AnalyzeOptions) *sameSite {
+	s := &sameSite{policy: opts.SameSitePolicy, host: siteHost(pageURL)}
+
+	switch s.policy {
+	case SameSiteRegistrableDomain:
+		// IP addresses and single-label hosts like localhost have no registrable domain, they stay exact. The public
+		// suffix list does not know about IPs and would happily call 216.34 the domain of 93.184.216.34.
+		hostname := strings.ToLower(pageURL.Hostname())
+		if net.ParseIP(hostname) != nil {
+			break
+		}
+		if domain, err := publicsuffix.EffectiveTLDPlusOne(hostname); err == nil {
+			s.domain = domain
+		}
+	case SameSiteHostList:
+		for _, h := range opts.InternalHosts {
+			s.hosts = append(s.hosts, strings.ToLower(strings.TrimSuffix(h, ".")))
+		}
+	}
+	return s
+}
+
+// isInternal reports whether u belongs to the same site as the analyzed page.
+func (s *sameSite) isInternal(u *url.URL) bool {
+	if u.Host == "" || siteHost(u) == s.host {
+		return true
+	}
+
+	hostname := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	switch s.policy {
+	case SameSiteRegistrableDomain:
+		return s.domain != "" && (hostname == s.domain || strings.HasSuffix(hostname, "."+s.domain))
+	case SameSiteHostList:
+		return slices.Contains(s.hosts, hostname)
+	default:
+		return false
+	}
+}
+
+func (s *sameSite) isInternalLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return s.isInternal(u)
+}
+
+// report echoes the policy in effect back to the caller.
+func (s *sameSite) report() model.SameSite {
+	return model.SameSite{Policy: string(s.policy), Domain: s.domain, InternalHosts: s.hosts}
+}
+
+// siteHost returns the lowercased host of u without a default port, so that example.com and example.com:443
+// are the same host.
+func siteHost(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		host = strings.TrimSuffix(host, ":"+port)
+	}
+	return host
+}
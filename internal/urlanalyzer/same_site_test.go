@@ -0,0 +1,78 @@
+package urlanalyzer
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSameSite_IsInternal(t *testing.T) {
+	tests := []struct {
+		name string
+		opts AnalyzeOptions
+		page string
+		link string
+		want bool
+	}{
+		{name: "exact host", page: "https://www.example.com/", link: "https://www.example.com/a", want: true},
+		{name: "exact host with default port", page: "https://www.example.com/", link: "https://WWW.example.com:443/a", want: true},
+		{name: "exact host with other port", page: "https://www.example.com/", link: "https://www.example.com:8443/a", want: false},
+		{name: "exact host apex", page: "https://www.example.com/", link: "https://example.com/", want: false},
+		{name: "exact host subdomain", page: "https://www.example.com/", link: "https://blog.example.com/", want: false},
+
+		{name: "registrable domain apex", opts: AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain},
+			page: "https://www.example.com/", link: "https://example.com/", want: true},
+		{name: "registrable domain subdomain", opts: AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain},
+			page: "https://www.example.com/", link: "https://blog.example.com/", want: true},
+		{name: "registrable domain lookalike", opts: AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain},
+			page: "https://www.example.com/", link: "https://notexample.com/", want: false},
+		{name: "registrable domain public suffix", opts: AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain},
+			page: "https://alice.github.io/", link: "https://bob.github.io/", want: false},
+		{name: "registrable domain multi-label suffix", opts: AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain},
+			page: "https://www.example.co.uk/", link: "https://shop.example.co.uk/", want: true},
+		{name: "registrable domain ip", opts: AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain},
+			page: "http://127.0.0.1:8080/", link: "http://127.0.0.2:8080/", want: false},
+		{name: "registrable domain ip sharing the last octets", opts: AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain},
+			page: "http://93.184.216.34/", link: "http://10.0.216.34/", want: false},
+		{name: "registrable domain private ip", opts: AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain},
+			page: "http://127.0.0.1/", link: "http://192.168.0.1/", want: false},
+		{name: "registrable domain same ip", opts: AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain},
+			page: "http://93.184.216.34/", link: "http://93.184.216.34/a", want: true},
+
+		{name: "host list", opts: AnalyzeOptions{SameSitePolicy: SameSiteHostList, InternalHosts: []string{"Shop.Example.net"}},
+			page: "https://www.example.com/", link: "https://shop.example.net/cart", want: true},
+		{name: "host list page host", opts: AnalyzeOptions{SameSitePolicy: SameSiteHostList, InternalHosts: []string{"shop.example.net"}},
+			page: "https://www.example.com/", link: "https://www.example.com/a", want: true},
+		{name: "host list other", opts: AnalyzeOptions{SameSitePolicy: SameSiteHostList, InternalHosts: []string{"shop.example.net"}},
+			page: "https://www.example.com/", link: "https://example.com/", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, _ := url.Parse(tt.page)
+			link, _ := url.Parse(tt.link)
+
+			site := newSameSite(page, tt.opts.withDefaults())
+			if got := site.isInternal(link); got != tt.want {
+				t.Errorf("isInternal(%q) on %q = %v, want %v", tt.link, tt.page, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameSite_Report(t *testing.T) {
+	page, _ := url.Parse("https://www.example.com/")
+
+	got := newSameSite(page, AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain}).report()
+	if got.Policy != "registrable_domain" || got.Domain != "example.com" {
+		t.Errorf("expected the registrable domain policy to be echoed, got %+v", got)
+	}
+}
+
+func TestSameSite_ReportIP(t *testing.T) {
+	page, _ := url.Parse("http://93.184.216.34/")
+
+	got := newSameSite(page, AnalyzeOptions{SameSitePolicy: SameSiteRegistrableDomain}).report()
+	if got.Domain != "" {
+		t.Errorf("expected an IP host to have no registrable domain, got %q", got.Domain)
+	}
+}
@@ -1,11 +1,11 @@
 package urlanalyzer
 
 import (
+	"bufio"
 	"context"
-	"fmt"
+	"errors"
 	"github.com/sendurangr/url-analyzer-api/internal/constants"
 	"github.com/sendurangr/url-analyzer-api/internal/model"
-	"github.com/sendurangr/url-analyzer-api/internal/utils"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
 	"log/slog"
@@ -16,37 +16,69 @@ import (
 
 // AnalyzerService Interface Definition for AnalyzerService
 type AnalyzerService interface {
-	AnalyzePage(url string) (*model.AnalyzerResult, error)
+	AnalyzePage(ctx context.Context, url string, opts AnalyzeOptions) (*model.AnalyzerResult, error)
+	// LinkCheckStats reports how busy the link checks shared by all analyses are.
+	LinkCheckStats() model.LinkCheckStats
 }
 
 // AnalyzerService implementation
 type analyzer struct {
 	client *http.Client
+	// pageClient shares client's transport but does not follow redirects, so fetchPage can record each hop
+	pageClient *http.Client
+	robots     *robotsCache
+	links      *linkCache
+	// scheduler bounds the link checks of all analyses together
+	scheduler *linkScheduler
 }
 
 // NewAnalyzer DI constructor for AnalyzerService
 func NewAnalyzer(client *http.Client) AnalyzerService {
-	return &analyzer{client: client}
+	pageClient := *client
+	// the page fetch is bounded by opts.FetchTimeout, which may be longer than the client's own timeout
+	pageClient.Timeout = 0
+	pageClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &analyzer{
+		client:     client,
+		pageClient: &pageClient,
+		robots:     newRobotsCache(client),
+		links:      newLinkCache(),
+		scheduler:  newLinkScheduler(constants.GlobalLinkCheckConcurrency),
+	}
+}
+
+func (a *analyzer) LinkCheckStats() model.LinkCheckStats {
+	return a.scheduler.stats()
 }
 
 // AnalyzePage fetches the HTML content of the given URL and analyzes it for various attributes.
-func (a *analyzer) AnalyzePage(rawURL string) (*model.AnalyzerResult, error) {
+// The fetch, parse and link checks are all bound to ctx and abort once it is canceled.
+// Failures are reported as *AnalyzeError.
+// Zero-valued fields in opts fall back to DefaultAnalyzeOptions.
+func (a *analyzer) AnalyzePage(ctx context.Context, rawURL string, opts AnalyzeOptions) (*model.AnalyzerResult, error) {
 	start := time.Now()
+	opts = opts.withDefaults()
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.ContextTimeout)
-	defer cancel()
+	fetchCtx, cancelFetch := context.WithTimeout(ctx, opts.FetchTimeout)
+	defer cancelFetch()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating HTTP request: %w", err)
-	}
-
-	utils.SetHeaders(req)
+	result := &model.AnalyzerResult{}
 
-	resp, err := a.client.Do(req)
+	resp, finalURL, err := a.fetchPage(fetchCtx, rawURL, opts, result)
 	if err != nil {
+		if isCanceled(ctx) {
+			return nil, ErrAnalysisCanceled
+		}
 		slog.Error("HTTP request failed", "url", rawURL, "error", err)
-		return nil, fmt.Errorf("failed to perform request: %w", err)
+		analyzeErr := classifyRequestError(err)
+		if result.Redirects.Loop || result.Redirects.TooManyRedirects {
+			// there is no result to carry the chain, so the error does
+			analyzeErr.Redirects = &result.Redirects
+		}
+		return nil, analyzeErr
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -56,36 +88,137 @@ func (a *analyzer) AnalyzePage(rawURL string) (*model.AnalyzerResult, error) {
 
 	if resp.StatusCode >= 400 {
 		slog.Warn("Non-OK HTTP response", "url", rawURL, "status", resp.StatusCode)
-		return nil, fmt.Errorf("HTTP error %d: %s — the URL is unreachable or returned an error",
-			resp.StatusCode, http.StatusText(resp.StatusCode))
+		return nil, newUpstreamStatusError(resp.StatusCode)
 	}
+	opts.reportProgress(model.ProgressEvent{Stage: StageFetched, Fetch: &model.FetchProgress{
+		FinalURL:   finalURL.String(),
+		StatusCode: resp.StatusCode,
+		Redirects:  result.Redirects.Count,
+	}})
 
-	parsedURL, err := url.Parse(rawURL)
+	// no point in downloading a body we already know we are going to reject
+	if opts.FailOnBodyTooLarge && resp.ContentLength > opts.MaxBodyBytes {
+		slog.Warn("Response body too large", "url", rawURL, "contentLength", resp.ContentLength)
+		return nil, newBodyTooLargeError(opts.MaxBodyBytes)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	limited := newLimitedBody(resp.Body, opts.MaxBodyBytes, opts.FailOnBodyTooLarge)
+	buffered := bufio.NewReaderSize(limited, charsetSniffLen)
+
+	mediaType, sniffed, err := detectMediaType(contentType, buffered)
+	result.Content = model.Content{MediaType: mediaType, Sniffed: sniffed, ContentLength: resp.ContentLength}
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse URL: %w", err)
+		if isCanceled(ctx) {
+			return nil, ErrAnalysisCanceled
+		}
+		slog.Warn("Unsupported content", "url", rawURL, "contentType", contentType, "error", err)
+		return nil, classifyRequestError(err)
 	}
 
-	doc, err := html.Parse(resp.Body)
+	body, encodingInfo, err := decodeBody(buffered, contentType)
 	if err != nil {
+		if isCanceled(ctx) {
+			return nil, ErrAnalysisCanceled
+		}
+		slog.Error("Failed to read response body", "url", rawURL, "error", err)
+		return nil, classifyRequestError(err)
+	}
+	result.Encoding = encodingInfo
+
+	doc, err := html.Parse(body)
+	if err != nil {
+		if isCanceled(ctx) {
+			return nil, ErrAnalysisCanceled
+		}
 		slog.Error("Failed to parse HTML", "url", rawURL, "error", err)
-		return nil, fmt.Errorf("failed to parse the HTML document: %w", err)
+
+		// read errors (timeouts, the body limit) surface through the parser, report them as what they are
+		var bodyErr *AnalyzeError
+		if errors.Is(fetchCtx.Err(), context.DeadlineExceeded) || errors.As(err, &bodyErr) {
+			return nil, classifyRequestError(err)
+		}
+		return nil, newAnalyzeError(CodeParseFailure, "failed to parse the HTML document", err)
 	}
+	cancelFetch()
 
-	result := &model.AnalyzerResult{}
-	a.iterateThroughDOM(doc, result, parsedURL)
+	result.Content.BytesRead = limited.read
+	result.Content.Truncated = limited.exceeded
+	if limited.exceeded {
+		slog.Warn("Response body truncated", "url", rawURL, "limit", opts.MaxBodyBytes)
+	}
+	content := result.Content
+	opts.reportProgress(model.ProgressEvent{Stage: StageParsed, Content: &content})
+
+	if opts.DetectSoft404 {
+		probeCtx, cancelProbe := context.WithTimeout(ctx, opts.FetchTimeout)
+		result.Soft404, result.Soft404Reason = detectSoft404(fingerprintDocument(doc, limited.read), func() *pageFingerprint {
+			return newSoft404Probes().probe(finalURL, func(target string) (*pageFingerprint, error) {
+				return a.fetchFingerprint(probeCtx, a.pageClient, target, opts.userAgent())
+			})
+		})
+		cancelProbe()
+	}
+
+	// relative links resolve against the page we actually landed on, not the one we were asked for,
+	// unless the document says otherwise with <base href>
+	baseURL, baseHref := documentBaseURL(doc, finalURL)
+	result.BaseURL, result.BaseHref = baseURL.String(), baseHref
+
+	pl := a.iterateThroughDOM(doc, result, baseURL, opts.enabledExtractors())
+	links, nonHTTP := splitByScheme(dedupeLinks(pl.links))
+	opts.reportProgress(model.ProgressEvent{Stage: StageMetadata, Metadata: &model.MetadataProgress{
+		HTMLVersion: result.HTMLVersion,
+		PageTitle:   result.PageTitle,
+		Headings:    result.Headings,
+		LinksFound:  len(links) + len(nonHTTP),
+	}})
+
+	site := newSameSite(finalURL, opts)
+	result.SameSite = site.report()
+	result.LinkAttributes = auditLinkAttributes(pl.links, site)
+
+	// links, fragments and resources are checked under one budget and one host throttle
+	checkCtx, run, endChecks := a.newLinkCheckRun(ctx, site, opts)
+	defer endChecks()
+
+	var outcomes []linkOutcome
+	if opts.SkipLinkCheck {
+		outcomes = classifyLinks(links, site, uncheckedSkipLinkCheck)
+	} else {
+		sample := sampleLinks(links, site, opts)
+		result.LinkSample = sample.report()
+		outcomes = a.checkLinksConcurrently(checkCtx, sample, run, opts)
+	}
+	tallyLinks(append(outcomes, nonHTTPOutcomes(nonHTTP)...), result, opts.IncludeLinkDetails)
+	result.FragmentLinks = a.validateFragments(checkCtx, pl, finalURL, run, opts)
+	result.Resources = a.inventoryResources(checkCtx, pl.resources, run, opts)
+	// resources and fragments without a verdict leave the result as incomplete as unchecked links do
+	if result.FragmentLinks.UncheckedDueToTimeout > 0 || hasUncheckedResources(result.Resources) {
+		result.Partial = true
+	}
+	if isCanceled(ctx) {
+		return nil, ErrAnalysisCanceled
+	}
 	result.TimeTakenToAnalyze = float32(time.Since(start).Seconds())
 	result.URL = rawURL
 
 	return result, nil
 }
 
-func (a *analyzer) iterateThroughDOM(n *html.Node, result *model.AnalyzerResult, baseURL *url.URL) {
-	var links []string
+// isCanceled reports whether ctx was canceled by the caller, as opposed to hitting its deadline.
+func isCanceled(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// iterateThroughDOM runs the enabled extractors over the document and returns the links it collected.
+func (a *analyzer) iterateThroughDOM(n *html.Node, result *model.AnalyzerResult, baseURL *url.URL, enabled map[Extractor]bool) *pageLinks {
+	pl := newPageLinks()
 
 	var collectLinks func(*html.Node)
 	collectLinks = func(n *html.Node) {
 
-		if n.Type == html.DoctypeNode {
+		if n.Type == html.DoctypeNode && enabled[ExtractorHTMLVersion] {
 			extractHtmlVersionFromDoctypeNode(n, result)
 		}
 
@@ -93,25 +226,31 @@ func (a *analyzer) iterateThroughDOM(n *html.Node, result *model.AnalyzerResult,
 			switch n.DataAtom {
 			case atom.Html:
 				// when html.DoctypeNode is not present in the html doc
-				extractHtmlVersionFromElementNode(n, result)
+				if enabled[ExtractorHTMLVersion] {
+					extractHtmlVersionFromElementNode(n, result)
+				}
 			case atom.Title:
-				extractTitleFromElementNode(n, result)
+				if enabled[ExtractorTitle] {
+					extractTitleFromElementNode(n, result)
+				}
 			case atom.A:
-				extractLinksFromElementNode(n, baseURL, &links)
+				if enabled[ExtractorLinks] {
+					extractLinksFromElementNode(n, baseURL, pl)
+				}
 			case atom.Form:
-				detectLoginFormFromElementNode(n, result)
-			case atom.H1:
-				result.Headings.H1++
-			case atom.H2:
-				result.Headings.H2++
-			case atom.H3:
-				result.Headings.H3++
-			case atom.H4:
-				result.Headings.H4++
-			case atom.H5:
-				result.Headings.H5++
-			case atom.H6:
-				result.Headings.H6++
+				if enabled[ExtractorLoginForm] {
+					detectLoginFormFromElementNode(n, result)
+				}
+			}
+
+			if enabled[ExtractorHeadings] {
+				countHeadingFromElementNode(n, result)
+			}
+			if enabled[ExtractorLinks] {
+				collectFragmentTargets(n, pl.targets)
+			}
+			if enabled[ExtractorResources] {
+				extractResourcesFromElementNode(n, baseURL, &pl.resources)
 			}
 		}
 
@@ -122,5 +261,22 @@ func (a *analyzer) iterateThroughDOM(n *html.Node, result *model.AnalyzerResult,
 
 	collectLinks(n)
 
-	a.checkLinksConcurrently(links, baseURL, result)
+	return pl
+}
+
+func countHeadingFromElementNode(n *html.Node, result *model.AnalyzerResult) {
+	switch n.DataAtom {
+	case atom.H1:
+		result.Headings.H1++
+	case atom.H2:
+		result.Headings.H2++
+	case atom.H3:
+		result.Headings.H3++
+	case atom.H4:
+		result.Headings.H4++
+	case atom.H5:
+		result.Headings.H5++
+	case atom.H6:
+		result.Headings.H6++
+	}
 }
@@ -1,13 +1,18 @@
 package urlanalyzer
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/sendurangr/url-analyzer-api/internal/constants"
 	"github.com/sendurangr/url-analyzer-api/internal/model"
+	"github.com/sendurangr/url-analyzer-api/internal/netguard"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 type analyzeTestCase struct {
@@ -30,6 +35,13 @@ var httpClient = &http.Client{
 	Timeout: constants.HttpClientTimeout,
 }
 
+// roundTripperFunc lets a test watch the requests a client sends.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
 func startTestServer(htmlContent string) *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, _ = fmt.Fprintln(w, htmlContent)
@@ -126,7 +138,7 @@ func TestAnalyzePage_TableDrivenTestCases(t *testing.T) {
 			ts := startTestServer(tc.htmlContent)
 			defer ts.Close()
 
-			result, err := service.AnalyzePage(ts.URL)
+			result, err := service.AnalyzePage(context.Background(), ts.URL, DefaultAnalyzeOptions())
 			if err != nil {
 				t.Fatalf("AnalyzePage failed: %v", err)
 			}
@@ -190,7 +202,7 @@ func TestAnalyzePage_InaccessibleLinks(t *testing.T) {
 
 	service := NewAnalyzer(httpClient)
 
-	result, err := service.AnalyzePage(ts.URL)
+	result, err := service.AnalyzePage(context.Background(), ts.URL, DefaultAnalyzeOptions())
 	if err != nil {
 		t.Fatalf("AnalyzePage failed: %v", err)
 	}
@@ -205,9 +217,11 @@ func TestAnalyzePage_InaccessibleLinks(t *testing.T) {
 
 func TestAnalyzePage_ErrorHandling(t *testing.T) {
 	tests := []struct {
-		name       string
-		handler    http.HandlerFunc
-		wantErrMsg string
+		name          string
+		handler       http.HandlerFunc
+		wantErrMsg    string
+		wantCode      ErrorCode
+		wantRetryable bool
 	}{
 		{
 			name: "HTTP error status",
@@ -215,6 +229,16 @@ func TestAnalyzePage_ErrorHandling(t *testing.T) {
 				http.Error(w, "Forbidden", http.StatusForbidden)
 			},
 			wantErrMsg: "HTTP error 403",
+			wantCode:   CodeUpstreamStatus,
+		},
+		{
+			name: "Retryable HTTP error status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Unavailable", http.StatusServiceUnavailable)
+			},
+			wantErrMsg:    "HTTP error 503",
+			wantCode:      CodeUpstreamStatus,
+			wantRetryable: true,
 		},
 	}
 
@@ -225,10 +249,245 @@ func TestAnalyzePage_ErrorHandling(t *testing.T) {
 
 			service := NewAnalyzer(httpClient)
 
-			_, err := service.AnalyzePage(ts.URL)
+			_, err := service.AnalyzePage(context.Background(), ts.URL, DefaultAnalyzeOptions())
 			if err == nil || !strings.Contains(err.Error(), tc.wantErrMsg) {
 				t.Fatalf("expected error containing %q, got %v", tc.wantErrMsg, err)
 			}
+
+			var analyzeErr *AnalyzeError
+			if !errors.As(err, &analyzeErr) {
+				t.Fatalf("expected *AnalyzeError, got %T", err)
+			}
+			if analyzeErr.Code != tc.wantCode || analyzeErr.Retryable != tc.wantRetryable {
+				t.Errorf("expected code %q retryable %v, got %q %v", tc.wantCode, tc.wantRetryable, analyzeErr.Code, analyzeErr.Retryable)
+			}
 		})
 	}
 }
+
+func TestAnalyzePage_CanceledByCaller(t *testing.T) {
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(block)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	service := NewAnalyzer(httpClient)
+
+	start := time.Now()
+	_, err := service.AnalyzePage(ctx, ts.URL, DefaultAnalyzeOptions())
+	if !errors.Is(err, ErrAnalysisCanceled) {
+		t.Fatalf("expected ErrAnalysisCanceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected analysis to abort promptly, took %v", elapsed)
+	}
+}
+
+func TestAnalyzePage_FetchTimeoutBeyondClientTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		_, _ = fmt.Fprint(w, `<html><head><title>Slow</title></head></html>`)
+	}))
+	defer ts.Close()
+
+	// the client gives up long before the requested fetch timeout
+	service := NewAnalyzer(&http.Client{Timeout: 20 * time.Millisecond})
+
+	result, err := service.AnalyzePage(context.Background(), ts.URL, AnalyzeOptions{FetchTimeout: time.Second, SkipLinkCheck: true})
+	if err != nil {
+		t.Fatalf("expected the fetch timeout to win over the client timeout, got %v", err)
+	}
+	if result.PageTitle != "Slow" {
+		t.Errorf("expected the slow page to be analyzed, got %q", result.PageTitle)
+	}
+}
+
+func TestAnalyzePage_Options(t *testing.T) {
+	simServer := simulateSuccessAndFailServer()
+	defer simServer.Close()
+
+	html := fmt.Sprintf(`
+		<!DOCTYPE html>
+		<html>
+			<head><title>Options</title></head>
+			<body>
+				<h1>Heading</h1>
+				<a href="%s/fail?n=1">One</a>
+				<a href="%s/fail?n=2">Two</a>
+				<a href="%s/fail?n=3">Three</a>
+			</body>
+		</html>
+	`, simServer.URL, simServer.URL, simServer.URL)
+
+	ts := startTestServer(html)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	t.Run("skip link check", func(t *testing.T) {
+		result, err := service.AnalyzePage(context.Background(), ts.URL, AnalyzeOptions{SkipLinkCheck: true})
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if result.ExternalLinks != 3 || result.InaccessibleExternalLinks != 0 || result.LinksChecked != 0 {
+			t.Errorf("expected 3 unchecked external links, got %+v", result)
+		}
+	})
+
+	t.Run("max links", func(t *testing.T) {
+		result, err := service.AnalyzePage(context.Background(), ts.URL, AnalyzeOptions{MaxLinks: 2})
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if result.ExternalLinks != 3 || result.InaccessibleExternalLinks != 2 || result.LinksChecked != 2 {
+			t.Errorf("expected 3 external links with 2 checked, got %+v", result)
+		}
+		if result.LinkSample.Discovered != 3 || result.LinkSample.Sampled != 2 || result.UncheckedDueToCap != 1 {
+			t.Errorf("expected 2 of 3 discovered links to be sampled, got %+v", result.LinkSample)
+		}
+	})
+
+	t.Run("selected extractors", func(t *testing.T) {
+		opts := AnalyzeOptions{Extractors: []Extractor{ExtractorTitle}}
+		result, err := service.AnalyzePage(context.Background(), ts.URL, opts)
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if result.PageTitle != "Options" {
+			t.Errorf("expected title to be extracted, got %q", result.PageTitle)
+		}
+		if result.Headings.H1 != 0 || result.ExternalLinks != 0 || result.HTMLVersion != "" {
+			t.Errorf("expected only the title extractor to run, got %+v", result)
+		}
+	})
+}
+
+func TestAnalyzePage_RedirectChain(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/start", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/en/", http.StatusFound)
+	})
+	mux.HandleFunc("/en/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<html><body><a href="about">About</a></body></html>`)
+	})
+	mux.HandleFunc("/en/about", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	result, err := service.AnalyzePage(context.Background(), ts.URL+"/start", DefaultAnalyzeOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	if result.Redirects.Count != 2 || len(result.Redirects.Chain) != 3 {
+		t.Fatalf("expected 2 redirects and 3 hops, got %+v", result.Redirects)
+	}
+	if result.Redirects.FinalURL != ts.URL+"/en/" {
+		t.Errorf("expected final URL %s/en/, got %s", ts.URL, result.Redirects.FinalURL)
+	}
+	if hop := result.Redirects.Chain[0]; hop.StatusCode != http.StatusMovedPermanently || hop.Location != "/moved" {
+		t.Errorf("unexpected first hop %+v", hop)
+	}
+	// "about" must resolve against /en/, where it is reachable
+	if result.InternalLinks != 1 || result.InaccessibleInternalLinks != 0 {
+		t.Errorf("expected relative link to resolve against the final URL, got %+v", result)
+	}
+
+	_, err = service.AnalyzePage(context.Background(), ts.URL+"/loop", DefaultAnalyzeOptions())
+	if !errors.Is(err, ErrRedirectLoop) {
+		t.Errorf("expected ErrRedirectLoop, got %v", err)
+	}
+	var analyzeErr *AnalyzeError
+	if !errors.As(err, &analyzeErr) || analyzeErr.Redirects == nil || !analyzeErr.Redirects.Loop || len(analyzeErr.Redirects.Chain) == 0 {
+		t.Errorf("expected the error to carry the looping chain, got %+v", analyzeErr)
+	}
+
+	oneRedirect := 1
+	_, err = service.AnalyzePage(context.Background(), ts.URL+"/start", AnalyzeOptions{MaxRedirects: &oneRedirect})
+	if !errors.As(err, &analyzeErr) || analyzeErr.Code != CodeTooManyRedirects {
+		t.Fatalf("expected a too_many_redirects error, got %v", err)
+	}
+	if analyzeErr.Redirects == nil || !analyzeErr.Redirects.TooManyRedirects || analyzeErr.Redirects.Count != 2 {
+		t.Errorf("expected the error to carry the chain followed so far, got %+v", analyzeErr.Redirects)
+	}
+
+	noRedirects := 0
+	_, err = service.AnalyzePage(context.Background(), ts.URL+"/start", AnalyzeOptions{MaxRedirects: &noRedirects})
+	if !errors.As(err, &analyzeErr) || analyzeErr.Code != CodeTooManyRedirects || analyzeErr.Redirects.Count != 1 {
+		t.Errorf("expected an explicit maxRedirects of 0 to follow no redirect, got %v", err)
+	}
+}
+
+func TestAnalyzePage_BlockedTarget(t *testing.T) {
+	ts := startTestServer(`<html><body>internal</body></html>`)
+	defer ts.Close()
+
+	guardedClient := &http.Client{
+		Timeout:   constants.HttpClientTimeout,
+		Transport: netguard.NewTransport(netguard.Policy{}),
+	}
+
+	_, err := NewAnalyzer(guardedClient).AnalyzePage(context.Background(), ts.URL, DefaultAnalyzeOptions())
+
+	var analyzeErr *AnalyzeError
+	if !errors.As(err, &analyzeErr) || analyzeErr.Code != CodeBlockedTarget {
+		t.Fatalf("expected %q error, got %v", CodeBlockedTarget, err)
+	}
+}
+
+func TestAnalyzePage_Progress(t *testing.T) {
+	simServer := simulateSuccessAndFailServer()
+	defer simServer.Close()
+
+	var links strings.Builder
+	for i := range 12 {
+		fmt.Fprintf(&links, `<a href="%s/ok?n=%d">Link</a>`, simServer.URL, i)
+	}
+	ts := startTestServer(`<html><head><title>Progress</title></head><body><h1>Hi</h1>` + links.String() + `</body></html>`)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+
+	var events []model.ProgressEvent
+	opts := AnalyzeOptions{Progress: func(event model.ProgressEvent) {
+		events = append(events, event)
+	}}
+	if _, err := service.AnalyzePage(context.Background(), ts.URL, opts); err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	var stages []string
+	for _, event := range events {
+		stages = append(stages, event.Stage)
+	}
+	// 12 links report after the 10th and after the last one
+	want := []string{StageFetched, StageParsed, StageMetadata, StageLinkCheck, StageLinkCheck}
+	if !slices.Equal(stages, want) {
+		t.Fatalf("expected stages %v, got %v", want, stages)
+	}
+
+	if meta := events[2].Metadata; meta.PageTitle != "Progress" || meta.Headings.H1 != 1 || meta.LinksFound != 12 {
+		t.Errorf("unexpected metadata event %+v", meta)
+	}
+	if last := events[4].Links; *last != (model.LinkProgress{Total: 12, Done: 12, Accessible: 12}) {
+		t.Errorf("unexpected final link progress %+v", last)
+	}
+}
@@ -0,0 +1,209 @@
+package urlanalyzer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/sendurangr/url-analyzer-api/internal/constants"
+	"github.com/sendurangr/url-analyzer-api/internal/utils"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Reasons a page is considered a soft 404
+const (
+	// soft404NotFoundText means the title or main heading reads like an error page
+	soft404NotFoundText = "not_found_text"
+	// soft404MatchesMissingPage means the page looks the same as what the host serves for a path that cannot exist
+	soft404MatchesMissingPage = "matches_missing_page"
+)
+
+var notFoundPattern = regexp.MustCompile(`(?i)\b404\b|not\s+found|page\s+(does\s+not|doesn't|no\s+longer)\s+exist|` +
+	`no\s+longer\s+available|nicht\s+gefunden|introuvable|no\s+encontrada|non\s+trovata`)
+
+// pageFingerprint is what soft-404 detection compares pages by.
+type pageFingerprint struct {
+	status  int
+	title   string
+	heading string
+	// length is the body length, capped at constants.Soft404SniffBytes
+	length int64
+}
+
+// fingerprintDocument takes the title and first <h1> of a parsed document.
+func fingerprintDocument(doc *html.Node, length int64) *pageFingerprint {
+	fp := &pageFingerprint{length: min(length, constants.Soft404SniffBytes)}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.DataAtom == atom.Title && fp.title == "":
+				fp.title = nodeText(n)
+			case n.DataAtom == atom.H1 && fp.heading == "":
+				fp.heading = nodeText(n)
+			}
+		}
+		for c := n.FirstChild; c != nil && (fp.title == "" || fp.heading == ""); c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return fp
+}
+
+// detectSoft404 decides whether a page that was served successfully is really an error page. probe returns what the
+// page's host serves for a random path, nil when the host answers those with a proper error status. It is only
+// called when the page's own text is not conclusive.
+func detectSoft404(fp *pageFingerprint, probe func() *pageFingerprint) (bool, string) {
+	if notFoundPattern.MatchString(fp.title) || notFoundPattern.MatchString(fp.heading) {
+		return true, soft404NotFoundText
+	}
+	if fp.title == "" && fp.heading == "" {
+		return false, ""
+	}
+	if probe := probe(); probe != nil && fp.title == probe.title && fp.heading == probe.heading && similarLength(fp.length, probe.length) {
+		return true, soft404MatchesMissingPage
+	}
+	return false, ""
+}
+
+// similarLength reports whether two body lengths are within 10% of each other.
+func similarLength(a, b int64) bool {
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff*10 <= max(a, b)
+}
+
+// fetchFingerprint GETs the start of a page with client and fingerprints it.
+func (a *analyzer) fetchFingerprint(ctx context.Context, client *http.Client, rawURL string, userAgent string) (*pageFingerprint, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	utils.SetHeaders(req)
+	identify(req, userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Error("Failed to close response body", "error", err)
+		}
+	}()
+
+	counted := &countingReader{r: io.LimitReader(resp.Body, constants.Soft404SniffBytes)}
+	body, _, err := decodeBody(counted, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	fp := fingerprintDocument(doc, counted.n)
+	fp.status = resp.StatusCode
+	return fp, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// soft404Probes requests a random, certainly missing path once per host and remembers what the host served for it.
+type soft404Probes struct {
+	mu    sync.Mutex
+	hosts map[string]*soft404Probe
+}
+
+type soft404Probe struct {
+	once sync.Once
+	fp   *pageFingerprint
+}
+
+func newSoft404Probes() *soft404Probes {
+	return &soft404Probes{hosts: make(map[string]*soft404Probe)}
+}
+
+// fingerprintFetcher fetches the fingerprint of a page, after whatever waiting the caller's politeness rules ask for.
+type fingerprintFetcher func(target string) (*pageFingerprint, error)
+
+// probe returns the fingerprint of a missing page on u's host, or nil when the host answers missing pages with an
+// error status or a redirect (or could not be probed), in which case there is nothing to compare against. fetch must
+// not follow redirects: hosts that redirect missing pages mostly send them to the home page, which would then look
+// like a missing page itself.
+func (p *soft404Probes) probe(u *url.URL, fetch fingerprintFetcher) *pageFingerprint {
+	key := u.Scheme + "://" + u.Host
+
+	p.mu.Lock()
+	entry, ok := p.hosts[key]
+	if !ok {
+		entry = &soft404Probe{}
+		p.hosts[key] = entry
+	}
+	p.mu.Unlock()
+
+	entry.once.Do(func() {
+		fp, err := fetch(key + "/" + randomProbePath())
+		if err != nil {
+			slog.Warn("Soft-404 probe failed", "host", u.Host, "error", err)
+			return
+		}
+		if fp.status >= 200 && fp.status < 300 {
+			entry.fp = fp
+		}
+	})
+	return entry.fp
+}
+
+func randomProbePath() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "url-analyzer-probe-" + hex.EncodeToString(b)
+}
+
+// flagSoft404 fetches an accessible link and records whether it is a soft 404. Its requests, and the host's probe,
+// wait for the host's delay and a scheduler slot like any other link check.
+func (a *analyzer) flagSoft404(ctx context.Context, outcome *linkOutcome, linkURL *url.URL, run *linkCheckRun, delay time.Duration) {
+	fetchWith := func(client *http.Client) fingerprintFetcher {
+		return func(target string) (*pageFingerprint, error) {
+			if err := run.throttle.wait(ctx, linkURL.Host, delay); err != nil {
+				return nil, err
+			}
+			release, err := run.share.acquire(ctx)
+			if err != nil {
+				return nil, err
+			}
+			defer release()
+			return a.fetchFingerprint(ctx, client, target, run.userAgent)
+		}
+	}
+
+	// the link is judged by where it leads, the probe by what the host answers for the missing path itself
+	fp, err := fetchWith(a.client)(outcome.link.url)
+	// whatever served the HEAD or ranged GET may not serve HTML, there is nothing to judge then
+	if err != nil || fp.status >= 300 {
+		return
+	}
+	outcome.soft404, _ = detectSoft404(fp, func() *pageFingerprint { return run.probes.probe(linkURL, fetchWith(a.pageClient)) })
+}
@@ -0,0 +1,207 @@
+package urlanalyzer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDetectSoft404(t *testing.T) {
+	noProbe := func() *pageFingerprint { return nil }
+	shell := &pageFingerprint{status: 200, title: "Shop", heading: "Welcome", length: 1000}
+
+	tests := []struct {
+		name       string
+		fp         *pageFingerprint
+		probe      func() *pageFingerprint
+		wantSoft   bool
+		wantReason string
+	}{
+		{name: "not found title", fp: &pageFingerprint{title: "Page Not Found | Shop"}, probe: noProbe, wantSoft: true, wantReason: soft404NotFoundText},
+		{name: "404 heading", fp: &pageFingerprint{title: "Shop", heading: "Error 404"}, probe: noProbe, wantSoft: true, wantReason: soft404NotFoundText},
+		{name: "german", fp: &pageFingerprint{title: "Seite nicht gefunden"}, probe: noProbe, wantSoft: true, wantReason: soft404NotFoundText},
+		{name: "regular page", fp: &pageFingerprint{title: "Shoes", heading: "Shoes"}, probe: noProbe},
+		{name: "404 inside a word", fp: &pageFingerprint{title: "Order 14045"}, probe: noProbe},
+		{name: "same as missing page", fp: &pageFingerprint{title: "Shop", heading: "Welcome", length: 1050},
+			probe: func() *pageFingerprint { return shell }, wantSoft: true, wantReason: soft404MatchesMissingPage},
+		{name: "same title, different size", fp: &pageFingerprint{title: "Shop", heading: "Welcome", length: 5000},
+			probe: func() *pageFingerprint { return shell }},
+		{name: "different heading", fp: &pageFingerprint{title: "Shop", heading: "Shoes", length: 1000},
+			probe: func() *pageFingerprint { return shell }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			soft, reason := detectSoft404(tt.fp, tt.probe)
+			if soft != tt.wantSoft || reason != tt.wantReason {
+				t.Errorf("expected (%v, %q), got (%v, %q)", tt.wantSoft, tt.wantReason, soft, reason)
+			}
+		})
+	}
+}
+
+func TestAnalyzePage_Soft404(t *testing.T) {
+	var probes atomic.Int32
+	shell := `<html><head><title>Shop</title></head><body><h1>Welcome</h1><p>Something went missing.</p></body></html>`
+
+	mux := http.NewServeMux()
+	// like many CMSs, every unknown path is answered with the same 200 page
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.Path) > 20 {
+			probes.Add(1)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, shell)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, `<html><head><title>Oops - page not found</title></head><body></body></html>`)
+	})
+	mux.HandleFunc("/shoes", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, `<html><head><title>Shoes</title></head><body><h1>Shoes</h1></body></html>`)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, `<html><head><title>Links</title></head><body>
+			<a href="/shoes">Shoes</a>
+			<a href="/gone">Gone</a>
+			<a href="/old-product">Old product</a>
+		</body></html>`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := NewAnalyzer(httpClient)
+	opts := AnalyzeOptions{DetectSoft404: true, IncludeLinkDetails: true}
+
+	t.Run("links", func(t *testing.T) {
+		result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts)
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if result.Soft404 {
+			t.Errorf("expected the analyzed page not to be a soft 404")
+		}
+		if result.Soft404Links != 2 || result.InaccessibleInternalLinks != 0 {
+			t.Errorf("expected 2 accessible soft-404 links, got %+v", result)
+		}
+
+		soft := map[string]bool{}
+		for _, link := range result.Links {
+			soft[link.Href] = link.Soft404
+		}
+		if soft["/shoes"] || !soft["/gone"] || !soft["/old-product"] {
+			t.Errorf("unexpected soft-404 flags %v", soft)
+		}
+		if probes.Load() < 1 {
+			t.Errorf("expected the host to be probed")
+		}
+	})
+
+	t.Run("page", func(t *testing.T) {
+		result, err := service.AnalyzePage(context.Background(), ts.URL+"/gone", opts)
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if !result.Soft404 || result.Soft404Reason != soft404NotFoundText {
+			t.Errorf("expected the page to be a soft 404 by its title, got %v (%q)", result.Soft404, result.Soft404Reason)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		result, err := service.AnalyzePage(context.Background(), ts.URL+"/page", AnalyzeOptions{})
+		if err != nil {
+			t.Fatalf("AnalyzePage failed: %v", err)
+		}
+		if result.Soft404Links != 0 {
+			t.Errorf("expected no soft-404 detection unless requested, got %d", result.Soft404Links)
+		}
+	})
+}
+
+func TestAnalyzePage_Soft404RedirectingProbe(t *testing.T) {
+	home := `<html><head><title>Shop</title></head><body><h1>Welcome</h1><a href="/">Home</a></body></html>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// unknown paths are sent to the home page rather than answered with a 404
+		if r.URL.Path != "/" {
+			http.Redirect(w, r, "/", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, home)
+	}))
+	defer ts.Close()
+
+	result, err := NewAnalyzer(httpClient).AnalyzePage(context.Background(), ts.URL+"/", AnalyzeOptions{DetectSoft404: true})
+	if err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+	if result.Soft404 || result.Soft404Links != 0 {
+		t.Errorf("expected the home page not to be compared with the probe redirecting to it, got %v (%q) and %d soft-404 links",
+			result.Soft404, result.Soft404Reason, result.Soft404Links)
+	}
+}
+
+func TestAnalyzePage_Soft404Politeness(t *testing.T) {
+	var mu sync.Mutex
+	// when requests are sent, the server sees them later the first time a connection has to be dialed
+	var starts []time.Time
+	requests := map[string]int{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/page" {
+			_, _ = fmt.Fprint(w, `<html><body><a href="/shoes">Shoes</a><img src="/logo.png"></body></html>`)
+			return
+		}
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `<html><head><title>Shoes</title></head><body><h1>Shoes</h1></body></html>`)
+	}))
+	defer ts.Close()
+
+	client := *httpClient
+	client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/page" && r.URL.Path != "/logo.png" {
+			mu.Lock()
+			starts = append(starts, time.Now())
+			mu.Unlock()
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	})
+	service := NewAnalyzer(&client)
+
+	delay := 50 * time.Millisecond
+	opts := AnalyzeOptions{
+		DetectSoft404:   true,
+		HostDelay:       delay,
+		LinkCheckMethod: LinkCheckHead,
+		CheckResources:  []ResourceType{ResourceImage},
+	}
+	if _, err := service.AnalyzePage(context.Background(), ts.URL+"/page", opts); err != nil {
+		t.Fatalf("AnalyzePage failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// the link's HEAD, its fingerprint GET and the host probe are all link checks against one host
+	if len(starts) < 3 {
+		t.Fatalf("expected at least 3 requests besides the page, got %d", len(starts))
+	}
+	for i := 1; i < len(starts); i++ {
+		// allow for timer granularity
+		if gap := starts[i].Sub(starts[i-1]); gap < delay-5*time.Millisecond {
+			t.Errorf("expected request %d to wait for the host delay, came after %s", i, gap)
+		}
+	}
+	if requests["/logo.png"] != 1 {
+		t.Errorf("expected resources to be checked without soft-404 detection, got %d requests for the image", requests["/logo.png"])
+	}
+}
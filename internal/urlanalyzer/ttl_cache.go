@@ -0,0 +1,64 @@
+package urlanalyzer
+
+import (
+	"container/list"
+	"time"
+)
+
+type ttlCacheEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// ttlCache is a map whose entries expire, holding at most maxEntries of them. Once full, storing evicts the oldest
+// entries first. It does no locking of its own, callers synchronize access.
+type ttlCache[V any] struct {
+	maxEntries int
+	entries    map[string]*list.Element
+	// order holds the entries oldest first
+	order *list.List
+}
+
+func newTTLCache[V any](maxEntries int) *ttlCache[V] {
+	return &ttlCache[V]{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// get returns the value stored for key, if it has not expired.
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	elem, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	entry := elem.Value.(*ttlCacheEntry[V])
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// put stores value for key until ttl has passed, making room by evicting the oldest entries.
+func (c *ttlCache[V]) put(key string, value V, ttl time.Duration) {
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	for len(c.entries) >= c.maxEntries {
+		c.remove(c.order.Front())
+	}
+	c.entries[key] = c.order.PushBack(&ttlCacheEntry[V]{key: key, value: value, expires: time.Now().Add(ttl)})
+}
+
+func (c *ttlCache[V]) len() int {
+	return len(c.entries)
+}
+
+func (c *ttlCache[V]) remove(elem *list.Element) {
+	delete(c.entries, c.order.Remove(elem).(*ttlCacheEntry[V]).key)
+}
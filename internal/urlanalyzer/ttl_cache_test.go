@@ -0,0 +1,42 @@
+package urlanalyzer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCache(t *testing.T) {
+	t.Run("evicts the oldest entries once full", func(t *testing.T) {
+		c := newTTLCache[int](3)
+		c.put("a", 1, time.Minute)
+		c.put("b", 2, time.Minute)
+		c.put("c", 3, time.Minute)
+		// storing a again makes it the newest entry
+		c.put("a", 10, time.Minute)
+		c.put("d", 4, time.Minute)
+
+		if c.len() != 3 {
+			t.Errorf("expected 3 entries, got %d", c.len())
+		}
+		if _, ok := c.get("b"); ok {
+			t.Error("expected the oldest entry to be evicted")
+		}
+		for key, want := range map[string]int{"a": 10, "c": 3, "d": 4} {
+			if got, ok := c.get(key); !ok || got != want {
+				t.Errorf("%s: expected %d, got %d (found %v)", key, want, got, ok)
+			}
+		}
+	})
+
+	t.Run("expired entries are gone", func(t *testing.T) {
+		c := newTTLCache[int](3)
+		c.put("a", 1, -time.Second)
+
+		if _, ok := c.get("a"); ok {
+			t.Error("expected an expired entry not to be returned")
+		}
+		if c.len() != 0 {
+			t.Errorf("expected the expired entry to be dropped, got %d entries", c.len())
+		}
+	})
+}
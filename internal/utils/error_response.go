@@ -1,9 +1,27 @@
 package utils
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sendurangr/url-analyzer-api/internal/model"
+)
 
-func RespondWithError(ctx *gin.Context, statusCode int, message string) {
-	ctx.JSON(statusCode, gin.H{
-		"message": message,
+// ErrorResponse is the JSON body of every error returned by the API.
+type ErrorResponse struct {
+	Message        string `json:"message"`
+	Code           string `json:"code"`
+	UpstreamStatus int    `json:"upstreamStatus,omitempty"`
+	Retryable      bool   `json:"retryable"`
+	// Redirects is the redirect chain that was followed before giving up on a redirect loop or too many redirects
+	Redirects *model.Redirects `json:"redirects,omitempty"`
+}
+
+func RespondWithError(ctx *gin.Context, statusCode int, code string, message string) {
+	RespondWithErrorResponse(ctx, statusCode, ErrorResponse{
+		Message: message,
+		Code:    code,
 	})
 }
+
+func RespondWithErrorResponse(ctx *gin.Context, statusCode int, body ErrorResponse) {
+	ctx.JSON(statusCode, body)
+}
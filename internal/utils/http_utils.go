@@ -15,6 +15,11 @@ func randomUserAgent() string {
 	return agents[rand.Intn(len(agents))]
 }
 
+// CrawlerUserAgent is the User-Agent of a crawler that follows the robots.txt rules written for token.
+func CrawlerUserAgent(token string) string {
+	return "Mozilla/5.0 (compatible; " + token + ")"
+}
+
 func SetHeaders(req *http.Request) {
 	req.Header.Set("User-Agent", randomUserAgent())
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")